@@ -1,94 +1,141 @@
 package goatquery
 
 import (
-	"fmt"
 	"reflect"
 	"strings"
 
-	"github.com/google/uuid"
 	"gorm.io/gorm"
 	"gorm.io/gorm/schema"
 )
 
-func Apply(db *gorm.DB, query Query, maxTop *int, searchFunc func(db *gorm.DB, searchTerm string) *gorm.DB, model interface{}) (*gorm.DB, *int64, error) {
-	if maxTop != nil && query.Top > *maxTop {
-		return nil, nil, fmt.Errorf("The value supplied for the query parameter 'Top' was greater than the maximum top allowed for this resource")
-	}
+// CountFunc returns the number of rows matching a query given the number of
+// rows loaded for its page, or nil when the query has no Count.
+type CountFunc func(pageLen int) (*int64, error)
 
-	if maxTop != nil && query.Top == 0 {
-		// If no top query was provided, set to max top.
-		query.Top = *maxTop
-	}
+// Apply is ApplyWith with the options of its arguments, where maxTop and
+// searchFunc may be nil. It doesn't return the applied Query, so to build a
+// response with a NextLink from a query without Top use ApplyWith.
+func Apply(db *gorm.DB, query Query, maxTop *int, searchFunc func(db *gorm.DB, searchTerm string) *gorm.DB, model interface{}) (*gorm.DB, *int64, error) {
+	db, _, count, err := ApplyWith(db, query, model, legacyOptions(maxTop, searchFunc)...)
+	return db, count, err
+}
 
-	// Filter
-	if query.Filter != "" {
-		filters := splitString(query.Filter)
-		where := strings.Builder{}
+// ApplyWith applies query to db, which queries the rows of model, returning
+// the count of the matching rows when query has Count. It also returns query
+// as applied, with the Top of WithMaxTop and the OrderBy of WithDefaultOrder
+// when it has none, which is the query to pass to BuildPagedResponse and
+// WithNextLink.
+//
+// Properties of model can deny being filtered on, sorted by or selected with
+// the goatquery struct tag, e.g. `goatquery:"filter,sort,-select"`, or all of
+// them with `goatquery:"-"`. Properties that cannot be selected are also left
+// out of the responses of BuildPagedResponse.
+func ApplyWith(db *gorm.DB, query Query, model interface{}, opts ...Option) (*gorm.DB, Query, *int64, error) {
+	o := newOptions(opts)
 
-		namer := db.Statement.NamingStrategy
+	if o.maxTop != nil && query.Top > *o.maxTop {
+		return nil, Query{}, nil, topExceedsMaxError()
+	}
 
-		v := reflect.ValueOf(model)
-		modelType := reflect.Indirect(v).Type().Elem()
+	query = o.defaults(query)
 
-		tableName := namer.TableName(modelType.Name())
+	db, c, err := apply(db, query, model, o)
+	if err != nil {
+		return nil, Query{}, nil, err
+	}
 
-		for i, filter := range filters {
-			opts := splitStringByWhitespace(strings.TrimSpace(filter))
+	if o.countFunc != nil {
+		*o.countFunc = c.deferred
+		return db, query, nil, nil
+	}
 
-			if len(opts) != 3 {
-				continue
-			}
+	count, err := c.count()
+	if err != nil {
+		return nil, Query{}, nil, err
+	}
 
-			if i > 0 {
-				prev := filters[i-1]
-				where.WriteString(fmt.Sprintf(" %s ", strings.TrimSpace(prev)))
-			}
+	return db, query, count, nil
+}
 
-			property := opts[0]
-			operand := opts[1]
-			value := opts[2]
+// apply applies query, with the defaults of o filled in, to db.
+func apply(db *gorm.DB, query Query, model interface{}, o options) (*gorm.DB, counter, error) {
+	if err := checkAllowedProperties(query, o.allowedProperties); err != nil {
+		return nil, counter{}, err
+	}
 
-			field, ok := modelType.FieldByNameFunc(func(s string) bool {
-				return strings.EqualFold(s, property)
-			})
+	modelType := modelTypeOf(model)
 
-			property = GetGormColumnNameByJsonTag(namer, tableName, modelType, property)
+	properties, err := modelPropertiesOf(db, modelType)
+	if err != nil {
+		return nil, counter{}, err
+	}
 
-			switch {
-			case ok && field.Type.Kind() == reflect.Bool:
-				where.WriteString(fmt.Sprintf("%s %s %s", property, filterOperations[operand], value))
-			case strings.EqualFold(operand, "contains"):
-				valueWithoutQuotes := getValueBetweenQuotes(value)
-				where.WriteString(fmt.Sprintf("%s %s '%%%s%%'", property, filterOperations[operand], valueWithoutQuotes))
-			case ok && field.Type == reflect.TypeOf(uuid.UUID{}):
-				where.WriteString(fmt.Sprintf("%s %s %s", property, filterOperations[operand], value))
-			default:
-				where.WriteString(fmt.Sprintf("LOWER(%s) %s LOWER(%s)", property, filterOperations[operand], value))
-			}
+	// Filter
+	if query.Filter != "" {
+		where, args, err := buildFilter(db, query.Filter, modelType, o.filter)
+		if err != nil {
+			return nil, counter{}, err
 		}
 
-		db = db.Where(where.String())
+		db = db.Where(where, args...)
 	}
 
 	// Search
-	if searchFunc != nil && query.Search != "" {
-		db = searchFunc(db, query.Search)
+	if o.search != nil && query.Search != "" {
+		db = o.search(db, query.Search)
 	}
 
-	// Count
-	var count int64
+	// Count, run once the rest of the query is known to be valid
+	var countDB *gorm.DB
 	if query.Count {
-		db.Count(&count)
+		countDB = countQuery(db)
 	}
 
 	// Order by
+	var terms []orderByTerm
 	if query.OrderBy != "" {
-		db = db.Order(query.OrderBy)
+		if terms, err = parseOrderBy(query.OrderBy, properties); err != nil {
+			return nil, counter{}, err
+		}
+	}
+
+	// Cursor
+	var keyset []keysetColumn
+	if query.Cursor != "" {
+		if db, keyset, err = seek(db, query, properties, terms, o.filter.dialectFor(db)); err != nil {
+			return nil, counter{}, err
+		}
+	} else {
+		for _, term := range terms {
+			db = db.Order(term.clause())
+		}
+	}
+
+	// Expand
+	var expanded []preload
+	if query.Expand != "" {
+		if expanded, err = buildExpand(db, query.Expand, modelType, o.filter); err != nil {
+			return nil, counter{}, err
+		}
+
+		for _, p := range expanded {
+			db = db.Preload(p.Field, p.Scope)
+		}
 	}
 
 	// Select
 	if query.Select != "" {
-		db = db.Select(query.Select)
+		columns, err := selectColumns(properties, query.Select)
+		if err != nil {
+			return nil, counter{}, err
+		}
+
+		// the next cursor is made from the keyset of the last row
+		for _, column := range keyset {
+			columns = appendColumns(columns, column.Field.DBName)
+		}
+
+		db = db.Select(appendKeyColumns(columns, expanded))
 	}
 
 	// Skip
@@ -101,26 +148,146 @@ func Apply(db *gorm.DB, query Query, maxTop *int, searchFunc func(db *gorm.DB, s
 		db = db.Limit(query.Top)
 	}
 
-	if query.Count {
-		return db, &count, nil
+	return db, counter{db: countDB, query: query}, nil
+}
+
+// countQuery returns a copy of db that only counts the rows matching it,
+// without the preloads, selects, ordering and paging of db, so neither
+// changes to db nor the statement of the count affect the other.
+func countQuery(db *gorm.DB) *gorm.DB {
+	// chaining on a new session clones the statement
+	tx := db.Session(&gorm.Session{}).Limit(-1)
+
+	tx.Statement.Preloads = nil
+	tx.Statement.Selects = nil
+	tx.Statement.Omits = nil
+	delete(tx.Statement.Clauses, "LIMIT")
+	delete(tx.Statement.Clauses, "ORDER BY")
+
+	return tx
+}
+
+// counter counts the rows matching query, through db, which is nil when
+// query has no Count.
+type counter struct {
+	db    *gorm.DB
+	query Query
+}
+
+// count runs the count query.
+func (c counter) count() (*int64, error) {
+	if c.db == nil {
+		return nil, nil
+	}
+
+	var count int64
+	if err := c.db.Count(&count).Error; err != nil {
+		return nil, err
+	}
+
+	return &count, nil
+}
+
+// deferred is the CountFunc of WithDeferredCount, which only runs the count
+// query when it doesn't follow from the length of the page.
+func (c counter) deferred(pageLen int) (*int64, error) {
+	if c.db == nil {
+		return nil, nil
+	}
+
+	// a page that isn't full is the last one, so the count is known unless
+	// the page follows a cursor or is empty because Skip is past the end
+	query := c.query
+	short := query.Top == 0 || pageLen < query.Top
+	followsCursor := query.Cursor != "" && query.Cursor != CursorStart
+	if short && !followsCursor && (pageLen > 0 || query.Skip == 0) {
+		count := int64(query.Skip + pageLen)
+		return &count, nil
+	}
+
+	return c.count()
+}
+
+// selectColumns resolves the comma separated json properties of sel to their columns.
+func selectColumns(properties *modelProperties, sel string) ([]string, error) {
+	var columns []string
+	for _, item := range splitList(sel) {
+		p, ok := properties.lookup(item.Value)
+		if !ok {
+			return nil, unknownPropertyError("Select", item.Value, item.Position)
+		}
+
+		if !p.allows(capSelect) {
+			return nil, propertyNotAllowedError("Select", item.Value, item.Position, capSelect)
+		}
+
+		if p.Relation != nil {
+			err := newQueryError(ErrInvalidSelect, "Select", item.Position, "The property '%s' supplied for the query parameter 'Select' is a relation and cannot be selected, use the query parameter 'Expand' to include it", item.Value)
+			err.Property = item.Value
+
+			return nil, err
+		}
+
+		columns = append(columns, p.Column)
 	}
 
-	return db, nil, nil
+	return columns, nil
 }
 
+// GetGormColumnNameByJsonTag returns the column of the property of t with the
+// json name property, named by namer for the table tableName, or property
+// when t has no such property.
 func GetGormColumnNameByJsonTag(namer schema.Namer, tableName string, t reflect.Type, property string) string {
-	for i := 0; i < t.NumField(); i++ {
-		f := t.Field(i)
-		v := strings.Split(f.Tag.Get("json"), ",")[0] // use split to ignore tag "options" like omitempty, etc.
-		if v == property {
-			settings := schema.ParseTagSetting(f.Tag.Get("gorm"), ";")
-			if settings["COLUMN"] != "" {
-				return settings["COLUMN"]
-			}
-
-			return namer.ColumnName(tableName, f.Name)
+	properties, err := responseProperties(t)
+	if err != nil {
+		return property
+	}
+
+	p, ok := properties.lookup(property)
+	if !ok {
+		return property
+	}
+
+	return columnName(namer, tableName, p.Field)
+}
+
+// modelTypeOf returns the struct type of model, which may be a struct, a slice
+// of structs or a pointer to either.
+func modelTypeOf(model interface{}) reflect.Type {
+	t := reflect.TypeOf(model)
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+
+	return t
+}
+
+type listItem struct {
+	Value    string
+	Position int
+}
+
+// splitList splits a comma separated query parameter into its trimmed items,
+// keeping the offset of each item within input.
+func splitList(input string) []listItem {
+	var items []listItem
+
+	start := 0
+	for i := 0; i <= len(input); i++ {
+		if i < len(input) && input[i] != ',' {
+			continue
+		}
+
+		item := input[start:i]
+		trimmed := strings.TrimSpace(item)
+		position := start + strings.Index(item, trimmed)
+		if trimmed == "" {
+			position = i
 		}
+
+		items = append(items, listItem{Value: trimmed, Position: position})
+		start = i + 1
 	}
 
-	return property
+	return items
 }
@@ -1,14 +1,22 @@
 package goatquery
 
 import (
+	"context"
+	"database/sql"
+	"encoding/base64"
 	"fmt"
+	"net/http"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"gorm.io/gorm/logger"
 )
 
@@ -21,14 +29,61 @@ type Base struct {
 type User struct {
 	Base
 
-	Firstname   string    `json:"firstname"`
-	Lastname    string    `json:"lastname"`
-	Email       string    `json:"email"`
-	UserName    string    `gorm:"column:display_name" json:"userName"`
-	PersonSex   string    `json:"gender"`
-	Age         uint      `json:"age"`
-	Contributor bool      `json:"contributor"`
-	PersonId    uuid.UUID `json:"personId"`
+	Firstname   string       `json:"firstname"`
+	Lastname    string       `json:"lastname"`
+	Email       string       `json:"email" goatquery:"casesensitive"`
+	UserName    string       `gorm:"column:display_name" json:"userName"`
+	PersonSex   string       `json:"gender"`
+	Age         uint         `json:"age"`
+	Contributor bool         `json:"contributor"`
+	PersonId    uuid.UUID    `json:"personId"`
+	CreatedAt   time.Time    `json:"createdAt"`
+	Password    string       `json:"password" goatquery:"-"`
+	Bio         string       `json:"bio" goatquery:"filter,-sort"`
+	Nickname    *string      `json:"nickname"`
+	ArchivedAt  sql.NullTime `json:"archivedAt"`
+
+	AddressId   uuid.UUID        `json:"-"`
+	Address     Address          `json:"address"`
+	ManagerId   *uuid.UUID       `json:"-"`
+	Manager     *User            `json:"manager"`
+	Permissions []UserPermission `json:"permissions"`
+	Tags        []Tag            `gorm:"many2many:user_tags" json:"tags"`
+}
+
+func (User) ExpandableProperties() []string {
+	return []string{"address", "permissions", "tags"}
+}
+
+type Address struct {
+	Base
+
+	Postcode  string    `json:"postcode"`
+	CountryId uuid.UUID `json:"-"`
+	Country   Country   `json:"country"`
+}
+
+func (Address) ExpandableProperties() []string {
+	return []string{"country"}
+}
+
+type Country struct {
+	Base
+
+	Name string `json:"name"`
+}
+
+type Tag struct {
+	Base
+
+	Name string `json:"name"`
+}
+
+type UserPermission struct {
+	Base
+
+	Name   string    `json:"name"`
+	UserId uuid.UUID `json:"-"`
 }
 
 func TestMain(m *testing.M) {
@@ -47,7 +102,7 @@ func setup() {
 	}
 	DB = db
 
-	db.AutoMigrate(&User{})
+	db.AutoMigrate(&Country{}, &Address{}, &User{}, &UserPermission{}, &Tag{})
 }
 
 func Test_EmptyQuery(t *testing.T) {
@@ -88,7 +143,12 @@ func Test_QueryWithTopGreaterThanMaxTop(t *testing.T) {
 
 	_, _, err := Apply(DB.Model(&User{}), query, &maxTop, nil, &[]User{})
 
-	assert.NotNil(t, err)
+	var queryErr *QueryError
+	if assert.ErrorAs(t, err, &queryErr) {
+		assert.ErrorIs(t, err, ErrTopExceedsMax)
+		assert.Equal(t, "Top", queryErr.Clause)
+		assert.Equal(t, http.StatusBadRequest, queryErr.Status)
+	}
 }
 
 func Test_QueryWithNilTopUsesMaxTop(t *testing.T) {
@@ -124,6 +184,217 @@ func Test_QueryWithSkip(t *testing.T) {
 	assert.Equal(t, expectedSql, sql)
 }
 
+// Cursor
+
+func Test_QueryWithCursorStart(t *testing.T) {
+	query := Query{Top: 2, OrderBy: "firstname", Cursor: CursorStart}
+
+	sql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		res, _, _ := Apply(tx.Model(&User{}), query, nil, nil, &[]User{})
+		return res.Find(&[]User{})
+	})
+
+	expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		return tx.Model(&User{}).Order(clause.OrderByColumn{Column: clause.Column{Name: "firstname"}}).Order(clause.OrderByColumn{Column: clause.Column{Name: "id"}}).Limit(query.Top).Find(&[]User{})
+	})
+
+	assert.Equal(t, expectedSql, sql)
+}
+
+func Test_QueryWithCursor(t *testing.T) {
+	id := uuid.New()
+	query := Query{Top: 2, OrderBy: "firstname", Cursor: testCursor(`{"k":["goat","` + id.String() + `"]}`)}
+
+	sql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		res, _, _ := Apply(tx.Model(&User{}), query, nil, nil, &[]User{})
+		return res.Find(&[]User{})
+	})
+
+	expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		return tx.Model(&User{}).Where("(firstname, id) > (?, ?)", "goat", id).Order(clause.OrderByColumn{Column: clause.Column{Name: "firstname"}}).Order(clause.OrderByColumn{Column: clause.Column{Name: "id"}}).Limit(query.Top).Find(&[]User{})
+	})
+
+	assert.Equal(t, expectedSql, sql)
+}
+
+func Test_QueryWithCursorBackward(t *testing.T) {
+	id := uuid.New()
+	query := Query{Top: 2, OrderBy: "firstname", Cursor: testCursor(`{"k":["goat","` + id.String() + `"],"b":true}`)}
+
+	sql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		res, _, _ := Apply(tx.Model(&User{}), query, nil, nil, &[]User{})
+		return res.Find(&[]User{})
+	})
+
+	expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		return tx.Model(&User{}).Where("(firstname, id) < (?, ?)", "goat", id).Order(clause.OrderByColumn{Column: clause.Column{Name: "firstname"}, Desc: true}).Order(clause.OrderByColumn{Column: clause.Column{Name: "id"}, Desc: true}).Limit(query.Top).Find(&[]User{})
+	})
+
+	assert.Equal(t, expectedSql, sql)
+}
+
+func Test_QueryWithCursorMixedDirections(t *testing.T) {
+	id := uuid.New()
+	query := Query{Top: 2, OrderBy: "age desc", Cursor: testCursor(`{"k":[21,"` + id.String() + `"]}`)}
+
+	sql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		res, _, _ := Apply(tx.Model(&User{}), query, nil, nil, &[]User{})
+		return res.Find(&[]User{})
+	})
+
+	expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		return tx.Model(&User{}).Where("age < ? OR (age = ? AND id > ?)", uint(21), uint(21), id).Order(clause.OrderByColumn{Column: clause.Column{Name: "age"}, Desc: true}).Order(clause.OrderByColumn{Column: clause.Column{Name: "id"}}).Limit(query.Top).Find(&[]User{})
+	})
+
+	assert.Equal(t, expectedSql, sql)
+}
+
+func Test_QueryWithCursorSelectKeepsKeys(t *testing.T) {
+	query := Query{Top: 2, OrderBy: "userName", Select: "firstname", Cursor: CursorStart}
+
+	sql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		res, _, _ := Apply(tx.Model(&User{}), query, nil, nil, &[]User{})
+		return res.Find(&[]User{})
+	})
+
+	expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		return tx.Model(&User{}).Order(clause.OrderByColumn{Column: clause.Column{Name: "display_name"}}).Order(clause.OrderByColumn{Column: clause.Column{Name: "id"}}).Select([]string{"firstname", "display_name", "id"}).Limit(query.Top).Find(&[]User{})
+	})
+
+	assert.Equal(t, expectedSql, sql)
+}
+
+func Test_QueryWithCursorPagesThroughRows(t *testing.T) {
+	names := []string{"e", "a", "d", "b", "c"}
+	for _, name := range names {
+		user := User{Base: Base{Id: uuid.New()}, Firstname: name, Lastname: "Cursor"}
+		assert.NoError(t, DB.Omit("Address").Create(&user).Error)
+	}
+
+	page := func(cursor string) PagedResponse[map[string]interface{}] {
+		query := Query{Top: 2, OrderBy: "firstname", Select: "firstname", Filter: "lastname eq 'Cursor'", Cursor: cursor}
+
+		var users []User
+		res, _, err := Apply(DB.Model(&User{}), query, nil, nil, &users)
+		assert.NoError(t, err)
+		assert.NoError(t, res.Find(&users).Error)
+
+		return BuildPagedResponse(users, query, nil)
+	}
+
+	firstnames := func(response PagedResponse[map[string]interface{}]) []interface{} {
+		var result []interface{}
+		for _, row := range response.Value {
+			result = append(result, row["firstname"])
+		}
+
+		return result
+	}
+
+	first := page(CursorStart)
+	assert.Equal(t, []interface{}{"a", "b"}, firstnames(first))
+	assert.Empty(t, first.PrevCursor)
+
+	second := page(first.NextCursor)
+	assert.Equal(t, []interface{}{"c", "d"}, firstnames(second))
+
+	third := page(second.NextCursor)
+	assert.Equal(t, []interface{}{"e"}, firstnames(third))
+	assert.Empty(t, third.NextCursor)
+
+	back := page(third.PrevCursor)
+	assert.Equal(t, []interface{}{"c", "d"}, firstnames(back))
+	assert.NotEmpty(t, back.NextCursor)
+
+	start := page(back.PrevCursor)
+	assert.Equal(t, []interface{}{"a", "b"}, firstnames(start))
+	assert.Equal(t, firstnames(second), firstnames(page(start.NextCursor)))
+}
+
+func Test_QueryWithCursorNullableOrderBy(t *testing.T) {
+	nickname := "goat"
+	for i, name := range []string{"a", "b", "c", "d"} {
+		user := User{Base: Base{Id: uuid.New()}, Firstname: name, Lastname: "CursorNull"}
+		if i%2 == 0 {
+			user.Nickname = &nickname
+		}
+
+		assert.NoError(t, DB.Omit("Address").Create(&user).Error)
+	}
+
+	_, _, err := Apply(DB.Model(&User{}), Query{Top: 1, OrderBy: "nickname", Filter: "lastname eq 'CursorNull'", Cursor: CursorStart}, nil, nil, &[]User{})
+	assert.ErrorIs(t, err, ErrInvalidOrderBy)
+
+	// every row is reached when paging by a property that cannot be null
+	var firstnames []string
+	for cursor := CursorStart; cursor != ""; {
+		query := Query{Top: 1, OrderBy: "firstname", Filter: "lastname eq 'CursorNull'", Cursor: cursor}
+
+		var users []User
+		res, _, err := Apply(DB.Model(&User{}), query, nil, nil, &users)
+		if !assert.NoError(t, err) || !assert.NoError(t, res.Find(&users).Error) {
+			return
+		}
+
+		for _, user := range users {
+			firstnames = append(firstnames, user.Firstname)
+		}
+
+		cursor = BuildPagedResponse(users, query, nil).NextCursor
+	}
+
+	assert.Equal(t, []string{"a", "b", "c", "d"}, firstnames)
+}
+
+type Employee struct {
+	Id     int    `json:"id"`
+	Name   string `json:"name"`
+	Salary int    `json:"salary" goatquery:"sort,-select"`
+}
+
+func Test_QueryWithCursorUnselectableOrderBy(t *testing.T) {
+	_, _, err := Apply(DB.Model(&Employee{}), Query{OrderBy: "salary desc", Cursor: CursorStart}, nil, nil, &[]Employee{})
+
+	var queryErr *QueryError
+	if assert.ErrorAs(t, err, &queryErr) {
+		assert.ErrorIs(t, err, ErrInvalidOrderBy)
+		assert.Equal(t, "The property 'salary' supplied for the query parameter 'OrderBy' cannot be selected and cannot be used with the query parameter 'Cursor'", queryErr.Message)
+	}
+
+	// without a cursor the values of salary aren't revealed
+	_, _, err = Apply(DB.Model(&Employee{}), Query{OrderBy: "salary desc"}, nil, nil, &[]Employee{})
+	assert.NoError(t, err)
+}
+
+func Test_QueryWithCursorInvalid(t *testing.T) {
+	tests := []struct {
+		query Query
+		err   error
+	}{
+		{Query{Cursor: CursorStart, Skip: 2}, ErrInvalidCursor},
+		{Query{Cursor: "not a cursor"}, ErrInvalidCursor},
+		{Query{Cursor: testCursor(`{"k":[]}`)}, ErrInvalidCursor},
+		{Query{Cursor: testCursor(`{"k":["goat"]}`), OrderBy: "firstname"}, ErrInvalidCursor},
+		{Query{Cursor: testCursor(`{"k":["goat","not a uuid"]}`), OrderBy: "firstname"}, ErrInvalidCursor},
+		{Query{Cursor: testCursor(`{"k":["21","` + uuid.NewString() + `"]}`), OrderBy: "age"}, ErrInvalidCursor},
+		{Query{Cursor: CursorStart, OrderBy: "address"}, ErrInvalidOrderBy},
+		{Query{Cursor: CursorStart, OrderBy: "archivedAt"}, ErrInvalidOrderBy},
+	}
+
+	for _, test := range tests {
+		_, _, err := Apply(DB.Model(&User{}), test.query, nil, nil, &[]User{})
+
+		var queryErr *QueryError
+		if assert.ErrorAs(t, err, &queryErr, test.query.Cursor) {
+			assert.ErrorIs(t, err, test.err, test.query.Cursor)
+		}
+	}
+}
+
+func testCursor(value string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(value))
+}
+
 // Count
 
 func Test_QueryWithCount(t *testing.T) {
@@ -134,14 +405,99 @@ func Test_QueryWithCount(t *testing.T) {
 		return res.Find(&[]User{})
 	})
 
-	var count int64
 	expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
-		return tx.Model(&User{}).Count(&count).Find(&[]User{})
+		return tx.Model(&User{}).Find(&[]User{})
 	})
 
 	assert.Equal(t, expectedSql, sql)
 }
 
+func Test_QueryWithCountIgnoresPaging(t *testing.T) {
+	for i := 0; i < 3; i++ {
+		user := User{Base: Base{Id: uuid.New()}, Firstname: fmt.Sprint(i), Lastname: "Count"}
+		assert.NoError(t, DB.Omit("Address").Create(&user).Error)
+	}
+
+	query := Query{Count: true, Top: 1, Skip: 1, OrderBy: "firstname desc", Select: "firstname,lastname", Expand: "permissions", Filter: "lastname eq 'Count'"}
+	db := DB.Model(&User{}).Preload("Address").Order("lastname")
+
+	var users []User
+	res, count, err := Apply(db, query, nil, nil, &users)
+	assert.NoError(t, err)
+	assert.NoError(t, res.Find(&users).Error)
+
+	if assert.NotNil(t, count) {
+		assert.Equal(t, int64(3), *count)
+	}
+
+	if assert.Len(t, users, 1) {
+		assert.Equal(t, "1", users[0].Firstname)
+	}
+}
+
+func Test_QueryWithCountIsNotRunForInvalidQuery(t *testing.T) {
+	recorder := &sqlRecorder{Interface: DB.Logger}
+
+	_, _, err := Apply(DB.Session(&gorm.Session{DryRun: true, Logger: recorder}).Model(&User{}), Query{Count: true, OrderBy: "unknown"}, nil, nil, &[]User{})
+
+	assert.Error(t, err)
+	assert.Empty(t, recorder.statements)
+}
+
+func Test_QueryWithDeferredCount(t *testing.T) {
+	tests := []struct {
+		query   Query
+		pageLen int
+		queried bool
+		count   int64
+	}{
+		{Query{Count: true, Top: 5}, 3, false, 3},
+		{Query{Count: true, Top: 5, Skip: 10}, 3, false, 13},
+		{Query{Count: true}, 3, false, 3},
+		{Query{Count: true, Top: 5}, 0, false, 0},
+		{Query{Count: true, Top: 5, Cursor: CursorStart}, 3, false, 3},
+		{Query{Count: true, Top: 3}, 3, true, 0},
+		{Query{Count: true, Top: 5, Skip: 10}, 0, true, 0},
+	}
+
+	for _, test := range tests {
+		recorder := &sqlRecorder{Interface: DB.Logger}
+
+		var countFunc CountFunc
+		_, _, _, err := ApplyWith(DB.Session(&gorm.Session{DryRun: true, Logger: recorder}).Model(&User{}), test.query, &[]User{}, WithDeferredCount(&countFunc))
+		assert.NoError(t, err)
+
+		count, err := countFunc(test.pageLen)
+		assert.NoError(t, err)
+
+		if test.queried {
+			assert.Equal(t, []string{"SELECT count(*) FROM `users`"}, recorder.statements, test)
+		} else if assert.NotNil(t, count, test) {
+			assert.Empty(t, recorder.statements, test)
+			assert.Equal(t, test.count, *count, test)
+		}
+	}
+
+	var countFunc CountFunc
+	_, _, _, err := ApplyWith(DB.Model(&User{}), Query{}, &[]User{}, WithDeferredCount(&countFunc))
+	assert.NoError(t, err)
+
+	count, err := countFunc(3)
+	assert.NoError(t, err)
+	assert.Nil(t, count)
+}
+
+// sqlRecorder is a logger recording the statements run.
+type sqlRecorder struct {
+	logger.Interface
+	statements []string
+}
+
+func (r *sqlRecorder) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	sql, _ := fc()
+	r.statements = append(r.statements, sql)
+}
+
 // Order by
 
 func Test_QueryWithOrderby(t *testing.T) {
@@ -153,7 +509,7 @@ func Test_QueryWithOrderby(t *testing.T) {
 	})
 
 	expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
-		return tx.Model(&User{}).Order("firstname").Find(&[]User{})
+		return tx.Model(&User{}).Order(clause.OrderByColumn{Column: clause.Column{Name: "firstname"}}).Find(&[]User{})
 	})
 
 	assert.Equal(t, expectedSql, sql)
@@ -168,7 +524,7 @@ func Test_QueryWithOrderbyAsc(t *testing.T) {
 	})
 
 	expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
-		return tx.Model(&User{}).Order("firstname asc").Find(&[]User{})
+		return tx.Model(&User{}).Order(clause.OrderByColumn{Column: clause.Column{Name: "firstname"}}).Find(&[]User{})
 	})
 
 	assert.Equal(t, expectedSql, sql)
@@ -183,7 +539,7 @@ func Test_QueryWithOrderbyDesc(t *testing.T) {
 	})
 
 	expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
-		return tx.Model(&User{}).Order("firstname desc").Find(&[]User{})
+		return tx.Model(&User{}).Order(clause.OrderByColumn{Column: clause.Column{Name: "firstname"}, Desc: true}).Find(&[]User{})
 	})
 
 	assert.Equal(t, expectedSql, sql)
@@ -198,12 +554,66 @@ func Test_QueryWithOrderbyMultiple(t *testing.T) {
 	})
 
 	expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
-		return tx.Model(&User{}).Order("firstname asc, lastname desc").Find(&[]User{})
+		return tx.Model(&User{}).Order(clause.OrderByColumn{Column: clause.Column{Name: "firstname"}}).Order(clause.OrderByColumn{Column: clause.Column{Name: "lastname"}, Desc: true}).Find(&[]User{})
+	})
+
+	assert.Equal(t, expectedSql, sql)
+}
+
+func Test_QueryWithOrderbyGormColumnRename(t *testing.T) {
+	query := Query{OrderBy: "userName DESC, gender"}
+
+	sql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		res, _, _ := Apply(tx.Model(&User{}), query, nil, nil, &[]User{})
+		return res.Find(&[]User{})
+	})
+
+	expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		return tx.Model(&User{}).Order(clause.OrderByColumn{Column: clause.Column{Name: "display_name"}, Desc: true}).Order(clause.OrderByColumn{Column: clause.Column{Name: "person_sex"}}).Find(&[]User{})
 	})
 
 	assert.Equal(t, expectedSql, sql)
 }
 
+func Test_QueryWithOrderbyInvalid(t *testing.T) {
+	tests := []struct {
+		orderBy  string
+		position int
+	}{
+		{orderBy: "firstname sideways", position: 10},
+		{orderBy: "firstname asc desc", position: 0},
+		{orderBy: "firstname asc,", position: 14},
+		{orderBy: "firstname; drop table users", position: 0},
+		{orderBy: "random()", position: 0},
+		{orderBy: "firstname, address", position: 11},
+		{orderBy: "permissions desc", position: 0},
+	}
+
+	for _, test := range tests {
+		_, _, err := Apply(DB.Model(&User{}), Query{OrderBy: test.orderBy}, nil, nil, &[]User{})
+
+		var queryErr *QueryError
+		if assert.ErrorAs(t, err, &queryErr, test.orderBy) {
+			assert.Equal(t, "OrderBy", queryErr.Clause, test.orderBy)
+			assert.Equal(t, test.position, queryErr.Position, test.orderBy)
+		}
+	}
+}
+
+func Test_QueryWithOrderbyInvalidColumn(t *testing.T) {
+	query := Query{OrderBy: "firstname asc, invalid desc"}
+
+	_, _, err := Apply(DB.Model(&User{}), query, nil, nil, &[]User{})
+
+	var queryErr *QueryError
+	if assert.ErrorAs(t, err, &queryErr) {
+		assert.ErrorIs(t, err, ErrUnknownProperty)
+		assert.Equal(t, "OrderBy", queryErr.Clause)
+		assert.Equal(t, "invalid", queryErr.Property)
+		assert.Equal(t, 15, queryErr.Position)
+	}
+}
+
 // Select
 
 func Test_QueryWithSelect(t *testing.T) {
@@ -215,7 +625,22 @@ func Test_QueryWithSelect(t *testing.T) {
 	})
 
 	expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
-		return tx.Model(&User{}).Select("firstname, lastname").Find(&[]User{})
+		return tx.Model(&User{}).Select([]string{"firstname", "lastname"}).Find(&[]User{})
+	})
+
+	assert.Equal(t, expectedSql, sql)
+}
+
+func Test_QueryWithSelectGormColumnRename(t *testing.T) {
+	query := Query{Select: "id, userName, gender"}
+
+	sql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		res, _, _ := Apply(tx.Model(&User{}), query, nil, nil, &[]User{})
+		return res.Find(&[]User{})
+	})
+
+	expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		return tx.Model(&User{}).Select([]string{"id", "display_name", "person_sex"}).Find(&[]User{})
 	})
 
 	assert.Equal(t, expectedSql, sql)
@@ -224,21 +649,191 @@ func Test_QueryWithSelect(t *testing.T) {
 func Test_QueryWithSelectInvalidColumn(t *testing.T) {
 	query := Query{Select: "firstname, invalid-col"}
 
+	res, _, err := Apply(DB.Model(&User{}), query, nil, nil, &[]User{})
+
+	var queryErr *QueryError
+	if assert.ErrorAs(t, err, &queryErr) {
+		assert.ErrorIs(t, err, ErrUnknownProperty)
+		assert.Equal(t, "Select", queryErr.Clause)
+		assert.Equal(t, "invalid-col", queryErr.Property)
+		assert.Equal(t, 11, queryErr.Position)
+	}
+	assert.Nil(t, res)
+}
+
+func Test_QueryWithSelectRelation(t *testing.T) {
+	tests := []struct {
+		query    Query
+		clause   string
+		position int
+	}{
+		{Query{Select: "firstname, address"}, "Select", 11},
+		{Query{Select: "permissions"}, "Select", 0},
+		{Query{Expand: "address($select=postcode,country)"}, "Expand", 25},
+	}
+
+	for _, test := range tests {
+		_, _, err := Apply(DB.Model(&User{}), test.query, nil, nil, &[]User{})
+
+		var queryErr *QueryError
+		if assert.ErrorAs(t, err, &queryErr) {
+			assert.ErrorIs(t, err, ErrInvalidSelect)
+			assert.Equal(t, test.clause, queryErr.Clause)
+			assert.Equal(t, test.position, queryErr.Position)
+		}
+	}
+}
+
+// Expand
+
+func Test_QueryWithExpand(t *testing.T) {
+	query := Query{Expand: "address($expand=country), permissions($filter=name eq 'admin';$orderby=name desc;$top=5;$select=name)"}
+
+	res, _, err := Apply(DB.Model(&User{}), query, nil, nil, &[]User{})
+
+	assert.NoError(t, err)
+	assert.Contains(t, res.Statement.Preloads, "Address")
+	assert.Contains(t, res.Statement.Preloads, "Permissions")
+	assert.NotContains(t, res.Statement.Preloads, "Tags")
+}
+
+func Test_QueryWithExpandSelectKeepsKeys(t *testing.T) {
+	query := Query{Select: "firstname", Expand: "address,permissions"}
+
 	sql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
 		res, _, _ := Apply(tx.Model(&User{}), query, nil, nil, &[]User{})
 		return res.Find(&[]User{})
 	})
 
 	expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
-		return tx.Model(&User{}).Select("firstname, invalid-col").Find(&[]User{})
+		return tx.Model(&User{}).Select([]string{"firstname", "address_id", "id"}).Find(&[]User{})
 	})
 
-	err := DB.Raw(expectedSql).Find(&[]User{}).Error
-
-	assert.Error(t, err)
 	assert.Equal(t, expectedSql, sql)
 }
 
+func Test_QueryWithExpandLoadsRelations(t *testing.T) {
+	country := Country{Base: Base{Id: uuid.New()}, Name: "UK"}
+	address := Address{Base: Base{Id: uuid.New()}, Postcode: "AB1", CountryId: country.Id}
+	user := User{
+		Base:      Base{Id: uuid.New()},
+		Firstname: "Expand",
+		AddressId: address.Id,
+		Permissions: []UserPermission{
+			{Base: Base{Id: uuid.New()}, Name: "admin"},
+			{Base: Base{Id: uuid.New()}, Name: "owner"},
+			{Base: Base{Id: uuid.New()}, Name: "reader"},
+		},
+	}
+	assert.NoError(t, DB.Create(&country).Error)
+	assert.NoError(t, DB.Create(&address).Error)
+	assert.NoError(t, DB.Omit("Address").Create(&user).Error)
+
+	query := Query{
+		Filter: fmt.Sprintf("id eq '%s'", user.Id),
+		Select: "firstname",
+		Expand: "address($select=postcode;$expand=country), permissions($filter=name ne 'reader';$orderby=name desc;$select=name)",
+	}
+
+	var users []User
+	res, _, err := Apply(DB.Model(&User{}), query, nil, nil, &users)
+	assert.NoError(t, err)
+	assert.NoError(t, res.Find(&users).Error)
+
+	if assert.Len(t, users, 1) {
+		assert.Equal(t, "AB1", users[0].Address.Postcode)
+		assert.Equal(t, "UK", users[0].Address.Country.Name)
+		if assert.Len(t, users[0].Permissions, 2) {
+			assert.Equal(t, "owner", users[0].Permissions[0].Name)
+			assert.Equal(t, "admin", users[0].Permissions[1].Name)
+		}
+	}
+
+	response := BuildPagedResponse(users, query, nil)
+
+	assert.Equal(t, map[string]interface{}{
+		"firstname": "Expand",
+		"address": map[string]interface{}{
+			"postcode": "AB1",
+			"country":  map[string]interface{}{"id": country.Id.String(), "name": "UK"},
+		},
+		"permissions": []interface{}{
+			map[string]interface{}{"name": "owner"},
+			map[string]interface{}{"name": "admin"},
+		},
+	}, response.Value[0])
+}
+
+func Test_QueryWithExpandTopPerRow(t *testing.T) {
+	var users []User
+	for _, firstname := range []string{"ExpandTop1", "ExpandTop2"} {
+		user := User{
+			Base:      Base{Id: uuid.New()},
+			Firstname: firstname,
+			Lastname:  "ExpandTop",
+			Permissions: []UserPermission{
+				{Base: Base{Id: uuid.New()}, Name: "admin"},
+				{Base: Base{Id: uuid.New()}, Name: "owner"},
+				{Base: Base{Id: uuid.New()}, Name: "reader"},
+				{Base: Base{Id: uuid.New()}, Name: "writer"},
+			},
+		}
+		assert.NoError(t, DB.Omit("Address").Create(&user).Error)
+	}
+
+	query := Query{
+		Filter:  "lastname eq 'ExpandTop'",
+		OrderBy: "firstname",
+		Expand:  "permissions($filter=name ne 'writer';$orderby=name desc;$top=2)",
+	}
+
+	res, _, err := Apply(DB.Model(&User{}), query, nil, nil, &users)
+	assert.NoError(t, err)
+	assert.NoError(t, res.Find(&users).Error)
+
+	if assert.Len(t, users, 2) {
+		for _, user := range users {
+			if assert.Len(t, user.Permissions, 2, user.Firstname) {
+				assert.Equal(t, "reader", user.Permissions[0].Name, user.Firstname)
+				assert.Equal(t, "owner", user.Permissions[1].Name, user.Firstname)
+			}
+		}
+	}
+}
+
+func Test_QueryWithExpandInvalid(t *testing.T) {
+	tests := []struct {
+		expand   string
+		err      error
+		position int
+	}{
+		{expand: "invalid", err: ErrUnknownProperty, position: 0},
+		{expand: "firstname", err: ErrInvalidExpand, position: 0},
+		{expand: "address, manager", err: ErrInvalidExpand, position: 9},
+		{expand: "address,", err: ErrInvalidExpand, position: 8},
+		{expand: "address(", err: ErrInvalidExpand, position: 7},
+		{expand: "address($top=-1)", err: ErrInvalidExpand, position: 13},
+		{expand: "address($top=1)", err: ErrInvalidExpand, position: 13},
+		{expand: "tags($select=name;$top=1)", err: ErrInvalidExpand, position: 23},
+		{expand: "address($skip=1)", err: ErrInvalidExpand, position: 8},
+		{expand: "address($expand=invalid)", err: ErrUnknownProperty, position: 16},
+		{expand: "permissions($filter=invalid eq 'admin')", err: ErrUnknownProperty, position: 20},
+		{expand: "permissions($select=name;$orderby=name sideways)", err: ErrInvalidOrderBy, position: 39},
+		{expand: "address($orderby=country)", err: ErrInvalidOrderBy, position: 17},
+	}
+
+	for _, test := range tests {
+		_, _, err := Apply(DB.Model(&User{}), Query{Expand: test.expand}, nil, nil, &[]User{})
+
+		var queryErr *QueryError
+		if assert.ErrorAs(t, err, &queryErr, test.expand) {
+			assert.ErrorIs(t, err, test.err, test.expand)
+			assert.Equal(t, "Expand", queryErr.Clause, test.expand)
+			assert.Equal(t, test.position, queryErr.Position, test.expand)
+		}
+	}
+}
+
 // Search
 
 func Test_QueryWithSearch(t *testing.T) {
@@ -305,7 +900,7 @@ func Test_QueryWithFilterEquals(t *testing.T) {
 	})
 
 	expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
-		return tx.Model(&User{}).Where("LOWER(firstname) = LOWER('goat')").Find(&[]User{})
+		return tx.Model(&User{}).Where("firstname = ? COLLATE NOCASE", "goat").Find(&[]User{})
 	})
 
 	assert.Equal(t, expectedSql, sql)
@@ -321,7 +916,7 @@ func Test_QueryWithFilterEqualsUUID(t *testing.T) {
 	})
 
 	expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
-		return tx.Model(&User{}).Where(fmt.Sprintf("person_id = '%s'", id)).Find(&[]User{})
+		return tx.Model(&User{}).Where("person_id = ?", id.String()).Find(&[]User{})
 	})
 
 	assert.Equal(t, expectedSql, sql)
@@ -336,7 +931,7 @@ func Test_QueryWithFilterNotEquals(t *testing.T) {
 	})
 
 	expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
-		return tx.Model(&User{}).Where("LOWER(firstname) <> LOWER('goat')").Find(&[]User{})
+		return tx.Model(&User{}).Where("firstname <> ? COLLATE NOCASE", "goat").Find(&[]User{})
 	})
 
 	assert.Equal(t, expectedSql, sql)
@@ -351,7 +946,7 @@ func Test_QueryWithFilterEqualsAndEquals(t *testing.T) {
 	})
 
 	expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
-		return tx.Model(&User{}).Where("LOWER(firstname) = LOWER('goat') and LOWER(lastname) = LOWER('query')").Find(&[]User{})
+		return tx.Model(&User{}).Where("firstname = ? COLLATE NOCASE and lastname = ? COLLATE NOCASE", "goat", "query").Find(&[]User{})
 	})
 
 	assert.Equal(t, expectedSql, sql)
@@ -366,7 +961,7 @@ func Test_QueryWithFilterEqualsAndNotEquals(t *testing.T) {
 	})
 
 	expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
-		return tx.Model(&User{}).Where("LOWER(firstname) = LOWER('goat') and LOWER(lastname) <> LOWER('query')").Find(&[]User{})
+		return tx.Model(&User{}).Where("firstname = ? COLLATE NOCASE and lastname <> ? COLLATE NOCASE", "goat", "query").Find(&[]User{})
 	})
 
 	assert.Equal(t, expectedSql, sql)
@@ -381,7 +976,7 @@ func Test_QueryWithFilterContains(t *testing.T) {
 	})
 
 	expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
-		return tx.Model(&User{}).Where("firstname like '%goat%'").Find(&[]User{})
+		return tx.Model(&User{}).Where("firstname like ? ESCAPE '\\'", "%goat%").Find(&[]User{})
 	})
 
 	assert.Equal(t, expectedSql, sql)
@@ -396,7 +991,7 @@ func Test_QueryWithFilterContainsAndEquals(t *testing.T) {
 	})
 
 	expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
-		return tx.Model(&User{}).Where("firstname like '%goat%' and LOWER(lastname) = LOWER('query')").Find(&[]User{})
+		return tx.Model(&User{}).Where("firstname like ? ESCAPE '\\' and lastname = ? COLLATE NOCASE", "%goat%", "query").Find(&[]User{})
 	})
 
 	assert.Equal(t, expectedSql, sql)
@@ -411,7 +1006,7 @@ func Test_QueryWithFilterContainsOrEquals(t *testing.T) {
 	})
 
 	expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
-		return tx.Model(&User{}).Where("firstname like '%goat%' or LOWER(lastname) = LOWER('query')").Find(&[]User{})
+		return tx.Model(&User{}).Where("firstname like ? ESCAPE '\\' or lastname = ? COLLATE NOCASE", "%goat%", "query").Find(&[]User{})
 	})
 
 	assert.Equal(t, expectedSql, sql)
@@ -426,7 +1021,7 @@ func Test_QueryWithFilterEqualsWithConjunction(t *testing.T) {
 	})
 
 	expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
-		return tx.Model(&User{}).Where("LOWER(firstname) = LOWER('goatand')").Find(&[]User{})
+		return tx.Model(&User{}).Where("firstname = ? COLLATE NOCASE", "goatand").Find(&[]User{})
 	})
 
 	assert.Equal(t, expectedSql, sql)
@@ -441,7 +1036,7 @@ func Test_QueryWithFilterEqualsWithConjunctionAndSpaces(t *testing.T) {
 	})
 
 	expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
-		return tx.Model(&User{}).Where("LOWER(firstname) = LOWER(' and ') or LOWER(lastname) = LOWER(' and or ')").Find(&[]User{})
+		return tx.Model(&User{}).Where("firstname = ? COLLATE NOCASE or lastname = ? COLLATE NOCASE", " and ", " and or ").Find(&[]User{})
 	})
 
 	assert.Equal(t, expectedSql, sql)
@@ -456,7 +1051,7 @@ func Test_QueryWithFilterGormColumnRename(t *testing.T) {
 	})
 
 	expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
-		return tx.Model(&User{}).Where("LOWER(display_name) = LOWER('John')").Find(&[]User{})
+		return tx.Model(&User{}).Where("display_name = ? COLLATE NOCASE", "John").Find(&[]User{})
 	})
 
 	assert.Equal(t, expectedSql, sql)
@@ -471,7 +1066,7 @@ func Test_QueryWithFilterPropertyDoesntMatchJsonProperty(t *testing.T) {
 	})
 
 	expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
-		return tx.Model(&User{}).Where("LOWER(person_sex) = LOWER('Male')").Find(&[]User{})
+		return tx.Model(&User{}).Where("person_sex = ? COLLATE NOCASE", "Male").Find(&[]User{})
 	})
 
 	assert.Equal(t, expectedSql, sql)
@@ -486,8 +1081,717 @@ func Test_QueryWithFilterAndOrInColumnName(t *testing.T) {
 	})
 
 	expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
-		return tx.Model(&User{}).Where("contributor = true").Find(&[]User{})
+		return tx.Model(&User{}).Where("contributor = 1").Find(&[]User{})
+	})
+
+	assert.Equal(t, expectedSql, sql)
+}
+
+func Test_QueryWithFilterPrecedence(t *testing.T) {
+	query := Query{Filter: "firstname eq 'a' or lastname eq 'b' and age eq 1"}
+
+	sql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		res, _, _ := Apply(tx.Model(&User{}), query, nil, nil, &[]User{})
+		return res.Find(&[]User{})
+	})
+
+	expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		return tx.Model(&User{}).Where("firstname = ? COLLATE NOCASE or lastname = ? COLLATE NOCASE and age = ?", "a", "b", 1).Find(&[]User{})
 	})
 
 	assert.Equal(t, expectedSql, sql)
 }
+
+func Test_QueryWithFilterParentheses(t *testing.T) {
+	query := Query{Filter: "(firstname eq 'a' or lastname eq 'b') and age eq 1"}
+
+	sql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		res, _, _ := Apply(tx.Model(&User{}), query, nil, nil, &[]User{})
+		return res.Find(&[]User{})
+	})
+
+	expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		return tx.Model(&User{}).Where("(firstname = ? COLLATE NOCASE or lastname = ? COLLATE NOCASE) and age = ?", "a", "b", 1).Find(&[]User{})
+	})
+
+	assert.Equal(t, expectedSql, sql)
+}
+
+func Test_QueryWithFilterNot(t *testing.T) {
+	query := Query{Filter: "not (firstname eq 'a' or lastname eq 'b')"}
+
+	sql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		res, _, _ := Apply(tx.Model(&User{}), query, nil, nil, &[]User{})
+		return res.Find(&[]User{})
+	})
+
+	expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		return tx.Model(&User{}).Where("NOT (firstname = ? COLLATE NOCASE or lastname = ? COLLATE NOCASE)", "a", "b").Find(&[]User{})
+	})
+
+	assert.Equal(t, expectedSql, sql)
+}
+
+func Test_QueryWithFilterComparisonOperators(t *testing.T) {
+	tests := []struct {
+		filter   string
+		where    string
+		argument interface{}
+	}{
+		{filter: "age gt 18", where: "age > ?", argument: 18},
+		{filter: "age ge 18", where: "age >= ?", argument: 18},
+		{filter: "age lt 18", where: "age < ?", argument: 18},
+		{filter: "age le 18", where: "age <= ?", argument: 18},
+		{filter: "createdAt le '2024-01-01'", where: "created_at <= ?", argument: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{filter: "createdAt gt '2024-01-01T10:30:00Z'", where: "created_at > ?", argument: time.Date(2024, 1, 1, 10, 30, 0, 0, time.UTC)},
+		{filter: "firstname gt 'm'", where: "firstname > ? COLLATE NOCASE", argument: "m"},
+		{filter: "createdAt ge 2024-01-01", where: "created_at >= ?", argument: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{filter: "createdAt lt 2024-01-01T10:30+01:00", where: "created_at < ?", argument: time.Date(2024, 1, 1, 10, 30, 0, 0, time.FixedZone("", 3600))},
+		{filter: "personId eq 1b4e28ba-2fa1-11d2-883f-0016d3cca427", where: "person_id = ?", argument: uuid.MustParse("1b4e28ba-2fa1-11d2-883f-0016d3cca427")},
+		{filter: "lastname eq 'O''Brien'", where: "lastname = ? COLLATE NOCASE", argument: "O'Brien"},
+	}
+
+	for _, test := range tests {
+		query := Query{Filter: test.filter}
+
+		sql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			res, _, _ := Apply(tx.Model(&User{}), query, nil, nil, &[]User{})
+			return res.Find(&[]User{})
+		})
+
+		expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			return tx.Model(&User{}).Where(test.where, test.argument).Find(&[]User{})
+		})
+
+		assert.Equal(t, expectedSql, sql, test.filter)
+	}
+}
+
+func Test_QueryWithFilterInvalidValueForType(t *testing.T) {
+	filters := []string{
+		"age gt 'eighteen'",
+		"age eq -1",
+		"age eq 1.5",
+		"contributor eq 'yes'",
+		"personId eq 'not-a-uuid'",
+		"createdAt gt 'yesterday'",
+		"firstname eq 1",
+		"contributor gt true",
+		"age contains '1'",
+		"age eq null",
+		"firstname eq null",
+		"firstname eq 2024-01-01",
+		"createdAt gt 21",
+		"personId eq 2024-01-01",
+		"age eq 1b4e28ba-2fa1-11d2-883f-0016d3cca427",
+	}
+
+	for _, filter := range filters {
+		_, _, err := Apply(DB.Model(&User{}), Query{Filter: filter}, nil, nil, &[]User{})
+
+		assert.ErrorIs(t, err, ErrInvalidFilter, filter)
+	}
+}
+
+func Test_QueryWithFilterNull(t *testing.T) {
+	tests := []struct {
+		filter   string
+		expected string
+	}{
+		{"nickname eq null", "nickname IS NULL"},
+		{"nickname ne null", "nickname IS NOT NULL"},
+		{"archivedAt eq null", "archived_at IS NULL"},
+		{"not (archivedAt ne null) and nickname eq 'goat'", "NOT (archived_at IS NOT NULL) and nickname = ? COLLATE NOCASE"},
+	}
+
+	for _, test := range tests {
+		sql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			res, _, _ := Apply(tx.Model(&User{}), Query{Filter: test.filter}, nil, nil, &[]User{})
+			return res.Find(&[]User{})
+		})
+
+		expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			return tx.Model(&User{}).Where(test.expected, "goat").Find(&[]User{})
+		})
+
+		assert.Equal(t, expectedSql, sql, test.filter)
+	}
+}
+
+func Test_QueryWithFilterNullRuns(t *testing.T) {
+	var users []User
+
+	res, _, err := Apply(DB.Model(&User{}), Query{Filter: "nickname eq null and archivedAt eq null"}, nil, nil, &users)
+	assert.NoError(t, err)
+	assert.NoError(t, res.Find(&users).Error)
+}
+
+func Test_QueryWithFilterNullInvalidOperator(t *testing.T) {
+	filters := []string{
+		"nickname gt null",
+		"archivedAt lt null",
+		"nickname contains null",
+	}
+
+	for _, filter := range filters {
+		_, _, err := Apply(DB.Model(&User{}), Query{Filter: filter}, nil, nil, &[]User{})
+
+		assert.ErrorIs(t, err, ErrInvalidFilter, filter)
+	}
+}
+
+func Test_QueryWithFilterCaseSensitiveProperty(t *testing.T) {
+	tests := []struct {
+		filter   string
+		where    string
+		argument interface{}
+	}{
+		{"email eq 'John.Doe@email.com'", "email = ?", "John.Doe@email.com"},
+		{"email contains 'Doe'", "email GLOB ?", "*Doe*"},
+		{"firstname contains 'Doe'", "firstname like ? ESCAPE '\\'", "%Doe%"},
+	}
+
+	for _, test := range tests {
+		sql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			res, _, _ := Apply(tx.Model(&User{}), Query{Filter: test.filter}, nil, nil, &[]User{})
+			return res.Find(&[]User{})
+		})
+
+		expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			return tx.Model(&User{}).Where(test.where, test.argument).Find(&[]User{})
+		})
+
+		assert.Equal(t, expectedSql, sql, test.filter)
+	}
+}
+
+func Test_QueryWithFilterCaseSensitiveRuns(t *testing.T) {
+	var users []User
+
+	res, _, err := Apply(DB.Model(&User{}), Query{Filter: "email contains 'Doe' and firstname gt 'a'"}, nil, nil, &users)
+	assert.NoError(t, err)
+	assert.NoError(t, res.Find(&users).Error)
+}
+
+func Test_QueryWithFilterStringFunctions(t *testing.T) {
+	tests := []struct {
+		filter string
+		where  string
+		args   []interface{}
+	}{
+		{"startswith(firstname, 'go')", "firstname like ? ESCAPE '\\'", []interface{}{"go%"}},
+		{"endswith(lastname, '_q')", "lastname like ? ESCAPE '\\'", []interface{}{"%\\_q"}},
+		{"endswith(email, '.com')", "email GLOB ?", []interface{}{"*.com"}},
+		{"contains(firstname, 'oa')", "firstname like ? ESCAPE '\\'", []interface{}{"%oa%"}},
+		{"tolower(firstname) eq 'goat'", "LOWER(firstname) = ? COLLATE NOCASE", []interface{}{"goat"}},
+		{"toupper(firstname) eq 'GOAT'", "UPPER(firstname) = ? COLLATE NOCASE", []interface{}{"GOAT"}},
+		{"trim(firstname) eq 'goat'", "TRIM(firstname) = ? COLLATE NOCASE", []interface{}{"goat"}},
+		{"length(firstname) gt 3", "LENGTH(firstname) > ?", []interface{}{3}},
+		{"indexof(lastname, 'oa') eq 1", "(instr(lastname, ?) - 1) = ?", []interface{}{"oa", 1}},
+		{"concat(firstname, lastname) eq 'goatquery'", "(firstname || lastname) = ? COLLATE NOCASE", []interface{}{"goatquery"}},
+		{"substring(firstname, 1, 2) eq 'oa'", "substr(firstname, ? + 1, ?) = ? COLLATE NOCASE", []interface{}{1, 2, "oa"}},
+		{"length(firstname) eq age", "LENGTH(firstname) = age", nil},
+		{"not startswith(address/postcode, 'AB')", "NOT (EXISTS (SELECT 1 FROM addresses WHERE addresses.id = users.address_id AND addresses.postcode like ? ESCAPE '\\'))", []interface{}{"AB%"}},
+		{"permissions/any(p: startswith(p/name, 'ad'))", "EXISTS (SELECT 1 FROM user_permissions WHERE user_permissions.user_id = users.id AND (user_permissions.name like ? ESCAPE '\\'))", []interface{}{"ad%"}},
+	}
+
+	for _, test := range tests {
+		sql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			res, _, _ := Apply(tx.Model(&User{}), Query{Filter: test.filter}, nil, nil, &[]User{})
+			return res.Find(&[]User{})
+		})
+
+		expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			return tx.Model(&User{}).Where(test.where, test.args...).Find(&[]User{})
+		})
+
+		assert.Equal(t, expectedSql, sql, test.filter)
+	}
+}
+
+func Test_QueryWithFilterStringFunctionsRun(t *testing.T) {
+	filter := "startswith(firstname, 'go') and endswith(email, '.com') and length(trim(lastname)) gt 3 and indexof(tolower(lastname), 'oa') eq 1 and substring(concat(firstname, lastname), 1) ne toupper(lastname)"
+
+	var users []User
+	res, _, err := Apply(DB.Model(&User{}), Query{Filter: filter}, nil, nil, &users)
+	assert.NoError(t, err)
+	assert.NoError(t, res.Find(&users).Error)
+}
+
+func Test_QueryWithFilterDateFunctions(t *testing.T) {
+	tests := []struct {
+		filter string
+		where  string
+		args   []interface{}
+	}{
+		{"year(createdAt) eq 2024", "CAST(strftime('%Y', created_at) AS INTEGER) = ?", []interface{}{2024}},
+		{"month(createdAt) ge 6", "CAST(strftime('%m', created_at) AS INTEGER) >= ?", []interface{}{6}},
+		{"day(archivedAt) eq 1", "CAST(strftime('%d', archived_at) AS INTEGER) = ?", []interface{}{1}},
+		{"hour(createdAt) lt 12 and minute(createdAt) eq 30", "CAST(strftime('%H', created_at) AS INTEGER) < ? and CAST(strftime('%M', created_at) AS INTEGER) = ?", []interface{}{12, 30}},
+		{"second(createdAt) eq day(createdAt)", "CAST(strftime('%S', created_at) AS INTEGER) = CAST(strftime('%d', created_at) AS INTEGER)", nil},
+	}
+
+	for _, test := range tests {
+		sql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			res, _, _ := Apply(tx.Model(&User{}), Query{Filter: test.filter}, nil, nil, &[]User{})
+			return res.Find(&[]User{})
+		})
+
+		expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			return tx.Model(&User{}).Where(test.where, test.args...).Find(&[]User{})
+		})
+
+		assert.Equal(t, expectedSql, sql, test.filter)
+	}
+}
+
+func Test_QueryWithFilterNow(t *testing.T) {
+	where, args, err := buildFilter(DB, "createdAt lt now() and archivedAt gt now()", reflect.TypeOf(User{}), filterOptions{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "created_at < strftime('%Y-%m-%d %H:%M:%f+00:00', 'now') and archived_at > strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')", where)
+	assert.Empty(t, args)
+}
+
+func Test_QueryWithFilterNowRuns(t *testing.T) {
+	for _, name := range []string{"past", "future"} {
+		createdAt := time.Now().UTC().Add(-time.Hour)
+		if name == "future" {
+			createdAt = time.Now().UTC().Add(time.Hour)
+		}
+
+		user := User{Base: Base{Id: uuid.New()}, Firstname: name, Lastname: "FilterNow", CreatedAt: createdAt}
+		assert.NoError(t, DB.Omit("Address").Create(&user).Error)
+	}
+
+	var users []User
+	res, _, err := Apply(DB.Model(&User{}), Query{Filter: "lastname eq 'FilterNow' and createdAt lt now()"}, nil, nil, &users)
+	assert.NoError(t, err)
+	assert.NoError(t, res.Find(&users).Error)
+
+	if assert.Len(t, users, 1) {
+		assert.Equal(t, "past", users[0].Firstname)
+	}
+}
+
+func Test_QueryWithFilterDateFunctionsRun(t *testing.T) {
+	filter := "year(createdAt) eq 2024 and month(createdAt) eq 1 and day(createdAt) eq 31 and hour(createdAt) eq 10 and second(archivedAt) eq 0 and createdAt lt now()"
+
+	var users []User
+	res, _, err := Apply(DB.Model(&User{}), Query{Filter: filter}, nil, nil, &users)
+	assert.NoError(t, err)
+	assert.NoError(t, res.Find(&users).Error)
+}
+
+func Test_QueryWithFilterArithmetic(t *testing.T) {
+	tests := []struct {
+		filter string
+		where  string
+		args   []interface{}
+	}{
+		{"age mod 2 eq 0", "age % ? = ?", []interface{}{2, 0}},
+		{"age add 1 mul 2 gt 10", "age + ? * ? > ?", []interface{}{1, 2, 10}},
+		{"(age add 1) mul 2 gt 10", "(age + ?) * ? > ?", []interface{}{1, 2, 10}},
+		{"age sub (1 sub age) lt -5", "age - (? - age) < ?", []interface{}{1, -5}},
+		{"age div 2.5 ge 1.5", "age / ? >= ?", []interface{}{2.5, 1.5}},
+		{"age mul age gt length(firstname) add 1", "age * age > LENGTH(firstname) + ?", []interface{}{1}},
+		{"manager/age eq age add 1", "EXISTS (SELECT 1 FROM users users_1 WHERE users_1.id = users.manager_id AND users_1.age = users.age + ?)", []interface{}{1}},
+	}
+
+	for _, test := range tests {
+		sql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			res, _, _ := Apply(tx.Model(&User{}), Query{Filter: test.filter}, nil, nil, &[]User{})
+			return res.Find(&[]User{})
+		})
+
+		expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			return tx.Model(&User{}).Where(test.where, test.args...).Find(&[]User{})
+		})
+
+		assert.Equal(t, expectedSql, sql, test.filter)
+	}
+}
+
+func Test_QueryWithFilterArithmeticRuns(t *testing.T) {
+	filter := "age mod 2 eq 0 and (age add 1) mul 2 gt 10 and age div 2.5 lt 100 and age sub 30 lt 0"
+
+	var users []User
+	res, _, err := Apply(DB.Model(&User{}), Query{Filter: filter}, nil, nil, &users)
+	assert.NoError(t, err)
+	assert.NoError(t, res.Find(&users).Error)
+}
+
+func Test_QueryWithFilterInvalidArithmetic(t *testing.T) {
+	filters := []string{
+		"firstname add 1 eq 2",
+		"createdAt add 1 gt 2",
+		"age add 'one' eq 2",
+		"age add 1 eq 'two'",
+		"age add 1 eq firstname",
+		"age mod 1.5 eq 0",
+		"age div 0 eq 1",
+		"age mod 0.0 eq 1",
+		"age mul 1.5 eq 2 mod 2.5",
+	}
+
+	for _, filter := range filters {
+		_, _, err := Apply(DB.Model(&User{}), Query{Filter: filter}, nil, nil, &[]User{})
+
+		assert.ErrorIs(t, err, ErrInvalidFilter, filter)
+	}
+}
+
+func Test_QueryWithFilterIn(t *testing.T) {
+	tests := []struct {
+		filter string
+		where  string
+		args   []interface{}
+	}{
+		{"age in (1, 2, 3)", "age IN (?, ?, ?)", []interface{}{1, 2, 3}},
+		{"firstname in ('goat', 'query')", "firstname COLLATE NOCASE IN (?, ?)", []interface{}{"goat", "query"}},
+		{"email in ('Goat@example.com')", "email IN (?)", []interface{}{"Goat@example.com"}},
+		{"personId in (1b4e28ba-2fa1-11d2-883f-0016d3cca427, '8f5d3c1e-2fa1-11d2-883f-0016d3cca427')", "person_id IN (?, ?)", []interface{}{uuid.MustParse("1b4e28ba-2fa1-11d2-883f-0016d3cca427"), uuid.MustParse("8f5d3c1e-2fa1-11d2-883f-0016d3cca427")}},
+		{"not (age in (1)) and length(firstname) in (4, 5)", "NOT (age IN (?)) and LENGTH(firstname) IN (?, ?)", []interface{}{1, 4, 5}},
+		{"manager/age in (30, 40)", "EXISTS (SELECT 1 FROM users users_1 WHERE users_1.id = users.manager_id AND users_1.age IN (?, ?))", []interface{}{30, 40}},
+	}
+
+	for _, test := range tests {
+		sql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			res, _, _ := Apply(tx.Model(&User{}), Query{Filter: test.filter}, nil, nil, &[]User{})
+			return res.Find(&[]User{})
+		})
+
+		expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			return tx.Model(&User{}).Where(test.where, test.args...).Find(&[]User{})
+		})
+
+		assert.Equal(t, expectedSql, sql, test.filter)
+	}
+}
+
+func Test_QueryWithFilterBooleanRuns(t *testing.T) {
+	var users []User
+	res, _, err := Apply(DB.Model(&User{}), Query{Filter: "contributor eq false and startswith(firstname, 'zz') ne true and contains(lastname, 'zz') eq false"}, nil, nil, &users)
+	assert.NoError(t, err)
+	assert.NoError(t, res.Find(&users).Error)
+}
+
+func Test_QueryWithFilterInRuns(t *testing.T) {
+	var users []User
+	res, _, err := Apply(DB.Model(&User{}), Query{Filter: "firstname in ('JOHN', 'jane') and age in (1, 2, 3)"}, nil, nil, &users)
+	assert.NoError(t, err)
+	assert.NoError(t, res.Find(&users).Error)
+}
+
+func Test_QueryWithFilterInvalidIn(t *testing.T) {
+	filters := []string{
+		"age in ('one', 'two')",
+		"nickname in ('goat', null)",
+		"address in (1)",
+		"age in (" + strings.Repeat("1, ", 100) + "1)",
+	}
+
+	for _, filter := range filters {
+		_, _, err := Apply(DB.Model(&User{}), Query{Filter: filter}, nil, nil, &[]User{})
+
+		assert.ErrorIs(t, err, ErrInvalidFilter, filter)
+	}
+}
+
+func Test_QueryWithFilterInvalidFunctionCall(t *testing.T) {
+	tests := []struct {
+		filter string
+		err    error
+	}{
+		{filter: "length(firstname)", err: ErrInvalidFilter},
+		{filter: "length(age) gt 1", err: ErrInvalidFilter},
+		{filter: "length(firstname) eq 'one'", err: ErrInvalidFilter},
+		{filter: "tolower(firstname) eq 1", err: ErrInvalidFilter},
+		{filter: "startswith(firstname, lastname)", err: ErrInvalidFilter},
+		{filter: "substring(firstname, 'a') eq 'b'", err: ErrInvalidFilter},
+		{filter: "firstname eq age", err: ErrInvalidFilter},
+		{filter: "concat(address/postcode, manager/firstname) eq 'a'", err: ErrInvalidFilter},
+		{filter: "length(invalid) gt 1", err: ErrUnknownProperty},
+		{filter: "startswith(password, 'a')", err: ErrPropertyNotAllowed},
+		{filter: "year(firstname) eq 2024", err: ErrInvalidFilter},
+		{filter: "year(createdAt) eq '2024'", err: ErrInvalidFilter},
+		{filter: "now() eq 1", err: ErrInvalidFilter},
+		{filter: "firstname eq now()", err: ErrInvalidFilter},
+	}
+
+	for _, test := range tests {
+		_, _, err := Apply(DB.Model(&User{}), Query{Filter: test.filter}, nil, nil, &[]User{})
+
+		assert.ErrorIs(t, err, test.err, test.filter)
+	}
+}
+
+func Test_QueryWithFilterContainsEscapesWildcards(t *testing.T) {
+	query := Query{Filter: "firstname contains '50%_off\\'"}
+
+	sql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		res, _, _ := Apply(tx.Model(&User{}), query, nil, nil, &[]User{})
+		return res.Find(&[]User{})
+	})
+
+	expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		return tx.Model(&User{}).Where("firstname like ? ESCAPE '\\'", "%50\\%\\_off\\\\%").Find(&[]User{})
+	})
+
+	assert.Equal(t, expectedSql, sql)
+}
+
+func Test_QueryWithFilterValueIsNotInterpolated(t *testing.T) {
+	tests := []struct {
+		filter string
+		value  string
+	}{
+		{"firstname eq ') or 1=1 --'", ") or 1=1 --"},
+		{"firstname eq 'x'') or 1=1 --'", "x') or 1=1 --"},
+	}
+
+	for _, test := range tests {
+		var users []User
+		res, _, err := Apply(DB.Model(&User{}), Query{Filter: test.filter}, nil, nil, &users)
+		assert.NoError(t, err)
+
+		assert.Equal(t, []interface{}{test.value}, res.Statement.Clauses["WHERE"].Expression.(clause.Where).Exprs[0].(clause.Expr).Vars)
+	}
+
+	_, _, err := Apply(DB.Model(&User{}), Query{Filter: "firstname eq 'x' ') or 1=1 --'"}, nil, nil, &[]User{})
+	assert.Error(t, err)
+}
+
+func Test_QueryWithFilterUnknownProperty(t *testing.T) {
+	query := Query{Filter: "firstname eq 'goat' and invalid eq 'query'"}
+
+	_, _, err := Apply(DB.Model(&User{}), query, nil, nil, &[]User{})
+
+	var queryErr *QueryError
+	if assert.ErrorAs(t, err, &queryErr) {
+		assert.ErrorIs(t, err, ErrUnknownProperty)
+		assert.Equal(t, "Filter", queryErr.Clause)
+		assert.Equal(t, "invalid", queryErr.Property)
+		assert.Equal(t, 24, queryErr.Position)
+	}
+}
+
+func Test_QueryWithFilterEmbeddedProperty(t *testing.T) {
+	id := uuid.New()
+	query := Query{Filter: fmt.Sprintf("id eq '%s'", id)}
+
+	sql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		res, _, _ := Apply(tx.Model(&User{}), query, nil, nil, &[]User{})
+		return res.Find(&[]User{})
+	})
+
+	expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		return tx.Model(&User{}).Where("id = ?", id).Find(&[]User{})
+	})
+
+	assert.Equal(t, expectedSql, sql)
+}
+
+func Test_QueryWithFilterNestedProperty(t *testing.T) {
+	query := Query{Filter: "address/postcode eq 'AB1' and firstname eq 'goat'"}
+
+	sql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		res, _, _ := Apply(tx.Model(&User{}), query, nil, nil, &[]User{})
+		return res.Find(&[]User{})
+	})
+
+	expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		return tx.Model(&User{}).Where("EXISTS (SELECT 1 FROM addresses WHERE addresses.id = users.address_id AND addresses.postcode = ? COLLATE NOCASE) and firstname = ? COLLATE NOCASE", "AB1", "goat").Find(&[]User{})
+	})
+
+	assert.Equal(t, expectedSql, sql)
+}
+
+func Test_QueryWithFilterDeeplyNestedProperty(t *testing.T) {
+	query := Query{Filter: "address/country/name eq 'UK'"}
+
+	sql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		res, _, _ := Apply(tx.Model(&User{}), query, nil, nil, &[]User{})
+		return res.Find(&[]User{})
+	})
+
+	expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		return tx.Model(&User{}).Where("EXISTS (SELECT 1 FROM addresses WHERE addresses.id = users.address_id AND EXISTS (SELECT 1 FROM countries WHERE countries.id = addresses.country_id AND countries.name = ? COLLATE NOCASE))", "UK").Find(&[]User{})
+	})
+
+	assert.Equal(t, expectedSql, sql)
+}
+
+func Test_QueryWithFilterSelfReferencingProperty(t *testing.T) {
+	query := Query{Filter: "manager/firstname eq 'goat'"}
+
+	sql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		res, _, _ := Apply(tx.Model(&User{}), query, nil, nil, &[]User{})
+		return res.Find(&[]User{})
+	})
+
+	expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		return tx.Model(&User{}).Where("EXISTS (SELECT 1 FROM users users_1 WHERE users_1.id = users.manager_id AND users_1.firstname = ? COLLATE NOCASE)", "goat").Find(&[]User{})
+	})
+
+	assert.Equal(t, expectedSql, sql)
+}
+
+func Test_QueryWithFilterNestedPropertyRuns(t *testing.T) {
+	query := Query{Filter: "address/country/name eq 'UK' or manager/address/postcode eq 'AB1'"}
+
+	var users []User
+	res, _, err := Apply(DB.Model(&User{}), query, nil, nil, &users)
+
+	assert.NoError(t, err)
+	assert.NoError(t, res.Find(&users).Error)
+}
+
+func Test_QueryWithFilterInvalidNestedProperty(t *testing.T) {
+	tests := []struct {
+		filter string
+		err    error
+	}{
+		{filter: "address/invalid eq 'AB1'", err: ErrUnknownProperty},
+		{filter: "invalid/postcode eq 'AB1'", err: ErrUnknownProperty},
+		{filter: "firstname/postcode eq 'AB1'", err: ErrInvalidFilter},
+		{filter: "permissions/name eq 'admin'", err: ErrInvalidFilter},
+	}
+
+	for _, test := range tests {
+		_, _, err := Apply(DB.Model(&User{}), Query{Filter: test.filter}, nil, nil, &[]User{})
+
+		assert.ErrorIs(t, err, test.err, test.filter)
+	}
+}
+
+func Test_QueryWithFilterAny(t *testing.T) {
+	query := Query{Filter: "permissions/any(p: p/name eq 'admin' or p/name eq 'owner')"}
+
+	sql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		res, _, _ := Apply(tx.Model(&User{}), query, nil, nil, &[]User{})
+		return res.Find(&[]User{})
+	})
+
+	expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		return tx.Model(&User{}).Where("EXISTS (SELECT 1 FROM user_permissions WHERE user_permissions.user_id = users.id AND (user_permissions.name = ? COLLATE NOCASE or user_permissions.name = ? COLLATE NOCASE))", "admin", "owner").Find(&[]User{})
+	})
+
+	assert.Equal(t, expectedSql, sql)
+}
+
+func Test_QueryWithFilterAnyWithoutPredicate(t *testing.T) {
+	query := Query{Filter: "not permissions/any()"}
+
+	sql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		res, _, _ := Apply(tx.Model(&User{}), query, nil, nil, &[]User{})
+		return res.Find(&[]User{})
+	})
+
+	expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		return tx.Model(&User{}).Where("NOT (EXISTS (SELECT 1 FROM user_permissions WHERE user_permissions.user_id = users.id))").Find(&[]User{})
+	})
+
+	assert.Equal(t, expectedSql, sql)
+}
+
+func Test_QueryWithFilterAll(t *testing.T) {
+	query := Query{Filter: "permissions/all(p: p/name ne 'admin')"}
+
+	sql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		res, _, _ := Apply(tx.Model(&User{}), query, nil, nil, &[]User{})
+		return res.Find(&[]User{})
+	})
+
+	expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		return tx.Model(&User{}).Where("NOT EXISTS (SELECT 1 FROM user_permissions WHERE user_permissions.user_id = users.id AND NOT (user_permissions.name <> ? COLLATE NOCASE))", "admin").Find(&[]User{})
+	})
+
+	assert.Equal(t, expectedSql, sql)
+}
+
+func Test_QueryWithFilterAnyManyToMany(t *testing.T) {
+	query := Query{Filter: "tags/any(t: t/name eq 'go')"}
+
+	sql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		res, _, _ := Apply(tx.Model(&User{}), query, nil, nil, &[]User{})
+		return res.Find(&[]User{})
+	})
+
+	expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		return tx.Model(&User{}).Where("EXISTS (SELECT 1 FROM tags JOIN user_tags ON user_tags.tag_id = tags.id WHERE user_tags.user_id = users.id AND (tags.name = ? COLLATE NOCASE))", "go").Find(&[]User{})
+	})
+
+	assert.Equal(t, expectedSql, sql)
+}
+
+func Test_QueryWithFilterAnyReferencingOuterProperties(t *testing.T) {
+	query := Query{Filter: "permissions/any(p: p/name eq firstname)"}
+
+	sql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		res, _, _ := Apply(tx.Model(&User{}), query, nil, nil, &[]User{})
+		return res.Find(&[]User{})
+	})
+
+	expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		return tx.Model(&User{}).Where("EXISTS (SELECT 1 FROM user_permissions WHERE user_permissions.user_id = users.id AND (user_permissions.name = users.firstname COLLATE NOCASE))").Find(&[]User{})
+	})
+
+	assert.Equal(t, expectedSql, sql)
+
+	query = Query{Filter: "manager/permissions/any(p: p/name eq 'admin' and age gt 18)"}
+
+	sql = DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		res, _, _ := Apply(tx.Model(&User{}), query, nil, nil, &[]User{})
+		return res.Find(&[]User{})
+	})
+
+	expectedSql = DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		return tx.Model(&User{}).Where("EXISTS (SELECT 1 FROM users users_1 WHERE users_1.id = users.manager_id AND EXISTS (SELECT 1 FROM user_permissions WHERE user_permissions.user_id = users_1.id AND (user_permissions.name = ? COLLATE NOCASE and users.age > ?)))", "admin", 18).Find(&[]User{})
+	})
+
+	assert.Equal(t, expectedSql, sql)
+}
+
+func Test_QueryWithFilterLambdaRuns(t *testing.T) {
+	query := Query{Filter: "permissions/any(p: p/name eq 'admin') and tags/all(t: t/name ne 'banned') and manager/tags/any()"}
+
+	var users []User
+	res, _, err := Apply(DB.Model(&User{}), query, nil, nil, &users)
+
+	assert.NoError(t, err)
+	assert.NoError(t, res.Find(&users).Error)
+}
+
+func Test_QueryWithFilterInvalidLambda(t *testing.T) {
+	tests := []struct {
+		filter string
+		err    error
+	}{
+		{filter: "address/any(a: a/postcode eq 'AB1')", err: ErrInvalidFilter},
+		{filter: "invalid/any()", err: ErrUnknownProperty},
+		{filter: "permissions/any(p: p/invalid eq 'admin')", err: ErrUnknownProperty},
+		{filter: "permissions/all()", err: ErrInvalidFilter},
+		{filter: "permissions/any(p p/name eq 'admin')", err: ErrInvalidFilter},
+	}
+
+	for _, test := range tests {
+		_, _, err := Apply(DB.Model(&User{}), Query{Filter: test.filter}, nil, nil, &[]User{})
+
+		assert.ErrorIs(t, err, test.err, test.filter)
+	}
+}
+
+func Test_QueryWithFilterMalformed(t *testing.T) {
+	query := Query{Filter: "firstname eq 'goat' lastname"}
+
+	res, _, err := Apply(DB.Model(&User{}), query, nil, nil, &[]User{})
+
+	var queryErr *QueryError
+	if assert.ErrorAs(t, err, &queryErr) {
+		assert.ErrorIs(t, err, ErrInvalidFilter)
+		assert.Equal(t, "Filter", queryErr.Clause)
+		assert.Equal(t, 20, queryErr.Position)
+	}
+	assert.Nil(t, res)
+}
@@ -0,0 +1,250 @@
+package goatquery
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Node is a node of a parsed filter expression.
+type Node interface {
+	// Pos returns the offset of the node in the filter string.
+	Pos() int
+	String() string
+}
+
+// PropertyNode references a property of the model by its json name. Path
+// holds the relations navigated to reach it, so "address/postcode" has the
+// Path ["address"] and the Name "postcode".
+type PropertyNode struct {
+	Position int
+	Path     []string
+	Name     string
+}
+
+func (n *PropertyNode) Pos() int { return n.Position }
+
+func (n *PropertyNode) String() string {
+	return strings.Join(append(append([]string{}, n.Path...), n.Name), "/")
+}
+
+type LiteralKind int
+
+const (
+	StringLiteral LiteralKind = iota
+	NumberLiteral
+	BooleanLiteral
+	NullLiteral
+	DateLiteral
+	DateTimeLiteral
+	GUIDLiteral
+)
+
+// LiteralNode is a constant value. Value holds the literal as written, except
+// that strings are unquoted and unescaped.
+type LiteralNode struct {
+	Position int
+	Kind     LiteralKind
+	Value    string
+}
+
+func (n *LiteralNode) Pos() int { return n.Position }
+
+func (n *LiteralNode) String() string {
+	if n.Kind == StringLiteral {
+		return "'" + strings.ReplaceAll(n.Value, "'", "''") + "'"
+	}
+
+	return n.Value
+}
+
+// ComparisonNode compares two operands, e.g. "age eq 21" or
+// "length(firstname) gt 3". Left is a property or a function call, and Right
+// is a literal, a property or a function call, or a *ListNode for "in".
+type ComparisonNode struct {
+	Position int
+	Left     Node
+	Operator string
+	Right    Node
+}
+
+func (n *ComparisonNode) Pos() int { return n.Position }
+
+func (n *ComparisonNode) String() string {
+	return fmt.Sprintf("%s %s %s", n.Left, n.Operator, n.Right)
+}
+
+// ListNode is the list of values compared with by "in", e.g.
+// "('active', 'pending')".
+type ListNode struct {
+	Position int
+	Values   []*LiteralNode
+}
+
+func (n *ListNode) Pos() int { return n.Position }
+
+func (n *ListNode) String() string {
+	values := make([]string, len(n.Values))
+	for i, value := range n.Values {
+		values[i] = value.String()
+	}
+
+	return "(" + strings.Join(values, ", ") + ")"
+}
+
+// CallNode calls a function, e.g. "startswith(firstname, 'go')". Function is
+// lower case.
+type CallNode struct {
+	Position  int
+	Function  string
+	Arguments []Node
+}
+
+func (n *CallNode) Pos() int { return n.Position }
+
+func (n *CallNode) String() string {
+	arguments := make([]string, len(n.Arguments))
+	for i, argument := range n.Arguments {
+		arguments[i] = argument.String()
+	}
+
+	return fmt.Sprintf("%s(%s)", n.Function, strings.Join(arguments, ", "))
+}
+
+// LogicalNode combines two expressions with "and" or "or".
+type LogicalNode struct {
+	Position int
+	Left     Node
+	Operator string
+	Right    Node
+}
+
+func (n *LogicalNode) Pos() int { return n.Position }
+
+func (n *LogicalNode) String() string {
+	return strings.Join([]string{groupString(n.Left, n), n.Operator, groupString(n.Right, n)}, " ")
+}
+
+// NotNode negates an expression.
+type NotNode struct {
+	Position int
+	Operand  Node
+}
+
+func (n *NotNode) Pos() int { return n.Position }
+
+func (n *NotNode) String() string {
+	return "not " + groupString(n.Operand, n)
+}
+
+// needsGrouping reports whether child has to be parenthesized to keep its
+// meaning when written inside parent.
+func needsGrouping(child Node, parent Node) bool {
+	logical, ok := child.(*LogicalNode)
+	if !ok {
+		return false
+	}
+
+	switch p := parent.(type) {
+	case *NotNode:
+		return true
+	case *LogicalNode:
+		return logical.Operator == "or" && p.Operator == "and"
+	}
+
+	return false
+}
+
+func groupString(child Node, parent Node) string {
+	if needsGrouping(child, parent) {
+		return "(" + child.String() + ")"
+	}
+
+	return child.String()
+}
+
+// ArithmeticNode applies the arithmetic operator "add", "sub", "mul", "div"
+// or "mod" to two values, e.g. "price mul quantity".
+type ArithmeticNode struct {
+	Position int
+	Left     Node
+	Operator string
+	Right    Node
+}
+
+func (n *ArithmeticNode) Pos() int { return n.Position }
+
+func (n *ArithmeticNode) String() string {
+	return strings.Join([]string{arithmeticString(n.Left, n, false), n.Operator, arithmeticString(n.Right, n, true)}, " ")
+}
+
+// arithmeticPrecedence is the precedence of the arithmetic operators, where
+// higher binds tighter.
+var arithmeticPrecedence = map[string]int{
+	"add": 1,
+	"sub": 1,
+	"mul": 2,
+	"div": 2,
+	"mod": 2,
+}
+
+// arithmeticNeedsGrouping reports whether child, the right operand of parent
+// when right is set, has to be parenthesized to keep its meaning.
+func arithmeticNeedsGrouping(child Node, parent *ArithmeticNode, right bool) bool {
+	arithmetic, ok := child.(*ArithmeticNode)
+	if !ok {
+		return false
+	}
+
+	precedence := arithmeticPrecedence[arithmetic.Operator]
+	parentPrecedence := arithmeticPrecedence[parent.Operator]
+
+	return precedence < parentPrecedence || (right && precedence == parentPrecedence)
+}
+
+func arithmeticString(child Node, parent *ArithmeticNode, right bool) string {
+	if arithmeticNeedsGrouping(child, parent, right) {
+		return "(" + child.String() + ")"
+	}
+
+	return child.String()
+}
+
+// walkOperands calls fn with every property among the operands of a
+// comparison, arithmetic operator or function call.
+func walkOperands(node Node, fn func(*PropertyNode)) {
+	switch n := node.(type) {
+	case *PropertyNode:
+		fn(n)
+	case *ComparisonNode:
+		walkOperands(n.Left, fn)
+		walkOperands(n.Right, fn)
+	case *ArithmeticNode:
+		walkOperands(n.Left, fn)
+		walkOperands(n.Right, fn)
+	case *CallNode:
+		for _, argument := range n.Arguments {
+			walkOperands(argument, fn)
+		}
+	}
+}
+
+// LambdaNode applies the "any" or "all" operator to a collection, e.g.
+// "permissions/any(p: p/name eq 'admin')". Predicate is nil for "any()",
+// which matches when the collection has any rows.
+type LambdaNode struct {
+	Position   int
+	Collection *PropertyNode
+	Function   string
+	Variable   string
+	Predicate  Node
+}
+
+func (n *LambdaNode) Pos() int { return n.Position }
+
+func (n *LambdaNode) String() string {
+	if n.Predicate == nil {
+		return fmt.Sprintf("%s/%s()", n.Collection, n.Function)
+	}
+
+	return fmt.Sprintf("%s/%s(%s: %s)", n.Collection, n.Function, n.Variable, n.Predicate)
+}
@@ -0,0 +1,88 @@
+package goatquery
+
+import (
+	"strings"
+)
+
+// capability is something clients can do with a property, which can be
+// denied with the goatquery struct tag.
+type capability uint8
+
+const (
+	capFilter capability = 1 << iota
+	capSort
+	capSelect
+
+	allCapabilities = capFilter | capSort | capSelect
+)
+
+var capabilityNames = map[string]capability{
+	"filter": capFilter,
+	"sort":   capSort,
+	"select": capSelect,
+}
+
+// parseCapabilities parses a goatquery struct tag, a comma separated list of
+// "filter", "sort" and "select" where a "-" prefix denies the capability and
+// "-" alone denies all of them, e.g. `goatquery:"filter,sort,-select"` or
+// `goatquery:"-,filter"`. Capabilities that aren't denied are allowed, and
+// names that aren't capabilities are ignored.
+func parseCapabilities(tag string) capability {
+	capabilities := allCapabilities
+
+	for _, name := range strings.Split(tag, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+
+		switch {
+		case name == "-":
+			capabilities = 0
+		case strings.HasPrefix(name, "-"):
+			capabilities &^= capabilityNames[name[1:]]
+		default:
+			capabilities |= capabilityNames[name]
+		}
+	}
+
+	return capabilities
+}
+
+// caseSensitivity is whether filters compare the strings of a property
+// ignoring case.
+type caseSensitivity uint8
+
+const (
+	// caseDefault follows the case sensitivity of the query.
+	caseDefault caseSensitivity = iota
+	caseSensitive
+	caseInsensitive
+)
+
+// parseCaseSensitivity parses the case sensitivity of a goatquery struct
+// tag, "casesensitive" or "caseinsensitive", e.g. `goatquery:"-sort,casesensitive"`.
+func parseCaseSensitivity(tag string) caseSensitivity {
+	sensitivity := caseDefault
+
+	for _, name := range strings.Split(tag, ",") {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "casesensitive":
+			sensitivity = caseSensitive
+		case "caseinsensitive":
+			sensitivity = caseInsensitive
+		}
+	}
+
+	return sensitivity
+}
+
+func propertyNotAllowedError(clause string, property string, position int, c capability) error {
+	action := map[capability]string{
+		capFilter: "filtered",
+		capSort:   "sorted by",
+		capSelect: "selected",
+	}[c]
+
+	err := newQueryError(ErrPropertyNotAllowed, clause, position, "The property '%s' supplied for the query parameter '%s' cannot be %s on this resource", property, clause, action)
+	err.Property = property
+
+	return err
+}
@@ -0,0 +1,111 @@
+package goatquery
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ParseCapabilities(t *testing.T) {
+	tests := []struct {
+		tag      string
+		expected capability
+	}{
+		{"", allCapabilities},
+		{"filter,sort,select", allCapabilities},
+		{"filter,sort,-select", capFilter | capSort},
+		{"-sort, -select", capFilter},
+		{"-", 0},
+		{"-,filter", capFilter},
+		{"-Filter", capSort | capSelect},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, parseCapabilities(test.tag), test.tag)
+	}
+}
+
+func Test_ParseCaseSensitivity(t *testing.T) {
+	tests := []struct {
+		tag      string
+		expected caseSensitivity
+	}{
+		{"", caseDefault},
+		{"-sort", caseDefault},
+		{"casesensitive", caseSensitive},
+		{"-sort, CaseInsensitive", caseInsensitive},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, parseCaseSensitivity(test.tag), test.tag)
+	}
+
+	assert.Equal(t, allCapabilities, parseCapabilities("casesensitive"))
+}
+
+func Test_QueryWithPropertyNotAllowed(t *testing.T) {
+	tests := []struct {
+		query    Query
+		clause   string
+		property string
+		position int
+	}{
+		{Query{Filter: "firstname eq 'goat' and password eq 'secret'"}, "Filter", "password", 24},
+		{Query{Filter: "manager/password eq 'secret'"}, "Filter", "manager/password", 0},
+		{Query{OrderBy: "firstname, bio desc"}, "OrderBy", "bio", 11},
+		{Query{OrderBy: "password"}, "OrderBy", "password", 0},
+		{Query{Select: "firstname,password"}, "Select", "password", 10},
+	}
+
+	for _, test := range tests {
+		_, _, err := Apply(DB.Model(&User{}), test.query, nil, nil, &[]User{})
+
+		var queryErr *QueryError
+		if assert.ErrorAs(t, err, &queryErr, test.query) {
+			assert.ErrorIs(t, err, ErrPropertyNotAllowed)
+			assert.Equal(t, test.clause, queryErr.Clause)
+			assert.Equal(t, test.property, queryErr.Property)
+			assert.Equal(t, test.position, queryErr.Position)
+		}
+	}
+
+	_, _, err := Apply(DB.Model(&User{}), Query{Filter: "bio eq 'goat'", Select: "bio"}, nil, nil, &[]User{})
+	assert.NoError(t, err)
+}
+
+func Test_QueryWithPropertyNotAllowedMessage(t *testing.T) {
+	tests := []struct {
+		query   Query
+		message string
+	}{
+		{Query{Filter: "password eq 'x'"}, "The property 'password' supplied for the query parameter 'Filter' cannot be filtered on this resource"},
+		{Query{OrderBy: "bio"}, "The property 'bio' supplied for the query parameter 'OrderBy' cannot be sorted by on this resource"},
+		{Query{Select: "password"}, "The property 'password' supplied for the query parameter 'Select' cannot be selected on this resource"},
+	}
+
+	for _, test := range tests {
+		_, _, err := Apply(DB.Model(&User{}), test.query, nil, nil, &[]User{})
+
+		assert.EqualError(t, err, test.message)
+	}
+}
+
+func Test_UnselectablePropertiesAreNotReturned(t *testing.T) {
+	data := []User{
+		{
+			Base:     Base{Id: uuid.New()},
+			Password: "secret",
+			Bio:      "goat",
+		},
+	}
+
+	res := BuildPagedResponse(data, Query{}, nil)
+
+	assert.NotContains(t, res.Value[0], "password")
+	assert.Equal(t, "goat", res.Value[0]["bio"])
+
+	res = BuildPagedResponse(data, Query{Select: "bio,password"}, nil)
+
+	assert.Equal(t, map[string]interface{}{"bio": "goat"}, res.Value[0])
+}
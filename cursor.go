@@ -0,0 +1,272 @@
+package goatquery
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// CursorStart is the Query.Cursor requesting the first page of keyset pagination.
+const CursorStart = "*"
+
+// cursor is the decoded form of Query.Cursor. Keys holds the json encoded
+// values of the keyset columns of the row the page starts after, and Backward
+// is set when the page ends before that row instead.
+type cursor struct {
+	Keys     []json.RawMessage `json:"k"`
+	Backward bool              `json:"b,omitempty"`
+}
+
+// keysetColumn is a column the rows are ordered by when paging with a cursor.
+type keysetColumn struct {
+	Field *schema.Field
+	Desc  bool
+}
+
+func invalidCursorError(format string, args ...interface{}) error {
+	return newQueryError(ErrInvalidCursor, "Cursor", 0, format, args...)
+}
+
+func decodeCursor(input string) (cursor, error) {
+	var c cursor
+	if input == "" || input == CursorStart {
+		return c, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(input)
+	if err == nil {
+		err = json.Unmarshal(data, &c)
+	}
+
+	if err != nil || len(c.Keys) == 0 {
+		return cursor{}, invalidCursorError("The value supplied for the query parameter 'Cursor' is not valid")
+	}
+
+	return c, nil
+}
+
+// encodeCursor returns the cursor of the page after row, or before it when
+// backward is set.
+func encodeCursor(row reflect.Value, columns []keysetColumn, backward bool) string {
+	c := cursor{Backward: backward}
+	for _, column := range columns {
+		value, _ := column.Field.ValueOf(context.Background(), row)
+
+		key, err := json.Marshal(value)
+		if err != nil {
+			return ""
+		}
+
+		c.Keys = append(c.Keys, key)
+	}
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		return ""
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// values decodes the keys of c into values of the types of columns.
+func (c cursor) values(columns []keysetColumn) ([]interface{}, error) {
+	if len(c.Keys) != len(columns) {
+		return nil, invalidCursorError("The value supplied for the query parameter 'Cursor' does not match the query parameter 'OrderBy'")
+	}
+
+	values := make([]interface{}, len(columns))
+	for i, column := range columns {
+		value := reflect.New(column.Field.FieldType)
+
+		decoder := json.NewDecoder(bytes.NewReader(c.Keys[i]))
+		decoder.UseNumber()
+		if err := decoder.Decode(value.Interface()); err != nil {
+			return nil, invalidCursorError("The value supplied for the query parameter 'Cursor' does not match the query parameter 'OrderBy'")
+		}
+
+		values[i] = value.Elem().Interface()
+	}
+
+	return values, nil
+}
+
+// keysetColumns returns the columns of terms followed by the primary key of
+// sch, which breaks ties so that every row has a unique position.
+func keysetColumns(sch *schema.Schema, terms []orderByTerm) ([]keysetColumn, error) {
+	if len(sch.PrimaryFields) == 0 {
+		return nil, errors.New("goatquery: " + sch.Name + " has no primary key to page by cursor")
+	}
+
+	var columns []keysetColumn
+	for _, term := range terms {
+		if term.Field == nil {
+			return nil, newQueryError(ErrInvalidOrderBy, "OrderBy", 0, "The property '%s' supplied for the query parameter 'OrderBy' cannot be used with the query parameter 'Cursor'", term.Property)
+		}
+
+		// cursors hold the values of the keyset, which clients can decode
+		if !term.Selectable {
+			return nil, newQueryError(ErrInvalidOrderBy, "OrderBy", 0, "The property '%s' supplied for the query parameter 'OrderBy' cannot be selected and cannot be used with the query parameter 'Cursor'", term.Property)
+		}
+
+		// rows cannot be compared with a null key, so they would be skipped
+		if _, nullable := nullableType(term.Field.FieldType); nullable && !term.Field.NotNull {
+			return nil, newQueryError(ErrInvalidOrderBy, "OrderBy", 0, "The property '%s' supplied for the query parameter 'OrderBy' can be null and cannot be used with the query parameter 'Cursor'", term.Property)
+		}
+
+		columns = append(columns, keysetColumn{Field: term.Field, Desc: term.Desc})
+	}
+
+	for _, field := range sch.PrimaryFields {
+		found := false
+		for _, column := range columns {
+			found = found || column.Field == field
+		}
+
+		if !found {
+			columns = append(columns, keysetColumn{Field: field})
+		}
+	}
+
+	return columns, nil
+}
+
+// seek orders db by the keyset of terms and, unless the cursor of query is
+// CursorStart, restricts it to the rows after the cursor, written in dialect.
+func seek(db *gorm.DB, query Query, properties *modelProperties, terms []orderByTerm, dialect Dialect) (*gorm.DB, []keysetColumn, error) {
+	if query.Skip > 0 {
+		return nil, nil, invalidCursorError("The query parameters 'Skip' and 'Cursor' cannot be combined")
+	}
+
+	c, err := decodeCursor(query.Cursor)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	columns, err := keysetColumns(properties.schema, terms)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if c.Keys != nil {
+		values, err := c.values(columns)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		where, args := seekCondition(dialect, columns, values, c.Backward)
+		db = db.Where(where, args...)
+	}
+
+	// a page before the cursor is read in reverse and put back in order by BuildPagedResponse
+	for _, column := range columns {
+		db = db.Order(clause.OrderByColumn{Column: clause.Column{Name: column.Field.DBName}, Desc: column.Desc != c.Backward})
+	}
+
+	return db, columns, nil
+}
+
+// seekCondition returns the where clause matching the rows that come after
+// values in the order of columns, or before them when backward is set.
+func seekCondition(dialect Dialect, columns []keysetColumn, values []interface{}, backward bool) (string, []interface{}) {
+	names := make([]string, len(columns))
+	for i, column := range columns {
+		names[i] = quoteIdentifier(dialect, column.Field.DBName)
+	}
+
+	operator := func(column keysetColumn) string {
+		if column.Desc != backward {
+			return "<"
+		}
+
+		return ">"
+	}
+
+	uniform := len(columns) > 1 && dialect.RowValues()
+	for _, column := range columns {
+		uniform = uniform && column.Desc == columns[0].Desc
+	}
+
+	// "(k1, k2) > (?, ?)" when every column is ordered the same way
+	if uniform {
+		placeholders := make([]string, len(columns))
+		for i := range columns {
+			placeholders[i] = "?"
+		}
+
+		return "(" + strings.Join(names, ", ") + ") " + operator(columns[0]) + " (" + strings.Join(placeholders, ", ") + ")", values
+	}
+
+	// "k1 > ? OR (k1 = ? AND k2 < ?)" otherwise, or without row values
+	var (
+		terms []string
+		args  []interface{}
+	)
+
+	for i, column := range columns {
+		var parts []string
+		for j := 0; j < i; j++ {
+			parts = append(parts, names[j]+" = ?")
+			args = append(args, values[j])
+		}
+
+		parts = append(parts, names[i]+" "+operator(column)+" ?")
+		args = append(args, values[i])
+
+		if len(parts) == 1 {
+			terms = append(terms, parts[0])
+		} else {
+			terms = append(terms, "("+strings.Join(parts, " AND ")+")")
+		}
+	}
+
+	return strings.Join(terms, " OR "), args
+}
+
+// pageCursors returns the cursors of the pages after and before res, which
+// was loaded with query.
+func pageCursors[T any](res []T, query Query) (next string, prev string) {
+	c, err := decodeCursor(query.Cursor)
+	if err != nil || query.Cursor == "" || len(res) == 0 {
+		return "", ""
+	}
+
+	properties, err := responseProperties(modelTypeOf(res))
+	if err != nil {
+		return "", ""
+	}
+
+	var terms []orderByTerm
+	if query.OrderBy != "" {
+		if terms, err = parseOrderBy(query.OrderBy, properties); err != nil {
+			return "", ""
+		}
+	}
+
+	columns, err := keysetColumns(properties.schema, terms)
+	if err != nil {
+		return "", ""
+	}
+
+	// without a Top the page can't be known to be the last, so a cursor may lead to an empty page
+	full := query.Top == 0 || len(res) >= query.Top
+	first := reflect.ValueOf(res[0])
+	last := reflect.ValueOf(res[len(res)-1])
+
+	if full || c.Backward {
+		next = encodeCursor(last, columns, false)
+	}
+
+	if c.Keys != nil && (full || !c.Backward) {
+		prev = encodeCursor(first, columns, true)
+	}
+
+	return next, prev
+}
@@ -0,0 +1,418 @@
+package goatquery
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Dialect writes the parts of a filter that differ between databases. The
+// dialect of a query is the one DialectOf returns for it, unless another is
+// set with WithDialect. A dialect can embed StandardDialect to only implement
+// the methods it writes differently.
+type Dialect interface {
+	// Quote quotes the identifier name, e.g. a table or column.
+	Quote(name string) string
+	// Bool returns the literal of value.
+	Bool(value bool) string
+	// RowValues reports whether rows can be compared as values, e.g.
+	// "(a, b) > (?, ?)".
+	RowValues() bool
+	// CompareFold returns a condition comparing left with right using the
+	// SQL operator, ignoring case.
+	CompareFold(left clause.Expr, operator string, right clause.Expr) clause.Expr
+	// InFold returns a condition matching the rows where left is one of
+	// values, ignoring case.
+	InFold(left clause.Expr, values []clause.Expr) clause.Expr
+	// Match returns a condition matching the rows where the string expr
+	// matches value as kind requires, ignoring case when fold is set.
+	Match(expr clause.Expr, kind MatchKind, value string, fold bool) clause.Expr
+	// Function returns a call to the filter function name with args, which
+	// have been checked against its parameters.
+	Function(name string, args []clause.Expr) clause.Expr
+	// Arithmetic returns left and right joined by the arithmetic operator of
+	// filters, "add", "sub", "mul", "div" or "mod". whole is set when both
+	// are whole numbers, which div divides without a remainder.
+	Arithmetic(left clause.Expr, operator string, right clause.Expr, whole bool) clause.Expr
+}
+
+// DialectOf returns the dialect of the database db is connected to, by the
+// name of its dialector: "sqlite", "postgres", "mysql" or "sqlserver". Other
+// databases get a dialect writing standard SQL.
+func DialectOf(db *gorm.DB) Dialect {
+	if db.Dialector == nil {
+		return StandardDialect{}
+	}
+
+	switch db.Dialector.Name() {
+	case "sqlite":
+		return sqliteDialect{}
+	case "postgres":
+		return postgresDialect{}
+	case "mysql":
+		return mysqlDialect{}
+	case "sqlserver":
+		return sqlserverDialect{}
+	}
+
+	return StandardDialect{}
+}
+
+// joinSQL joins parts, which are strings written as they are or expressions,
+// into one expression with the vars of the expressions in the order written.
+func joinSQL(parts ...interface{}) clause.Expr {
+	var sql strings.Builder
+	var vars []interface{}
+
+	for _, part := range parts {
+		switch p := part.(type) {
+		case string:
+			sql.WriteString(p)
+		case clause.Expr:
+			sql.WriteString(p.SQL)
+			vars = append(vars, p.Vars...)
+		}
+	}
+
+	return clause.Expr{SQL: sql.String(), Vars: vars}
+}
+
+// joinList joins exprs into one expression separated by sep.
+func joinList(exprs []clause.Expr, sep string) clause.Expr {
+	parts := make([]interface{}, 0, 2*len(exprs))
+	for i, expr := range exprs {
+		if i > 0 {
+			parts = append(parts, sep)
+		}
+
+		parts = append(parts, expr)
+	}
+
+	return joinSQL(parts...)
+}
+
+// bind returns a placeholder bound to value.
+func bind(value interface{}) clause.Expr {
+	return clause.Expr{SQL: "?", Vars: []interface{}{value}}
+}
+
+// quoteIdentifier returns the identifier name, which may be qualified with
+// dots, quoting the parts that cannot be written as they are: keywords and
+// anything but lower case letters, digits and underscores.
+func quoteIdentifier(d Dialect, name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		if !plainIdentifier(part) {
+			parts[i] = d.Quote(part)
+		}
+	}
+
+	return strings.Join(parts, ".")
+}
+
+func plainIdentifier(name string) bool {
+	if name == "" || sqlKeywords[name] {
+		return false
+	}
+
+	for i, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c == '_':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// sqlKeywords are the keywords reserved by the databases that are likely to
+// be used as the names of tables and columns.
+var sqlKeywords = map[string]bool{
+	"all": true, "and": true, "any": true, "as": true, "asc": true, "between": true,
+	"by": true, "case": true, "check": true, "column": true, "constraint": true,
+	"create": true, "cross": true, "current": true, "default": true, "delete": true,
+	"desc": true, "distinct": true, "drop": true, "else": true, "end": true,
+	"exists": true, "false": true, "from": true, "full": true, "group": true,
+	"having": true, "in": true, "index": true, "inner": true, "insert": true,
+	"into": true, "is": true, "join": true, "key": true, "left": true, "like": true,
+	"limit": true, "not": true, "null": true, "offset": true, "on": true, "or": true,
+	"order": true, "outer": true, "primary": true, "references": true, "right": true,
+	"select": true, "set": true, "table": true, "then": true, "to": true, "true": true,
+	"union": true, "unique": true, "update": true, "user": true, "using": true,
+	"values": true, "when": true, "where": true, "with": true,
+}
+
+// Like returns a LIKE condition matching expr against the pattern of value,
+// as Dialect.Match does, where escape escapes the wildcards of value and
+// escapeChar is the string literal of the escape character it uses, e.g. `'\'`.
+func Like(expr clause.Expr, kind MatchKind, value string, fold bool, escape func(string) string, escapeChar string) clause.Expr {
+	pattern := bind(kind.Pattern(value, "%", escape))
+	if fold {
+		return joinSQL("LOWER(", expr, ") like LOWER(", pattern, ") ESCAPE "+escapeChar)
+	}
+
+	return joinSQL(expr, " like ", pattern, " ESCAPE "+escapeChar)
+}
+
+// StandardDialect writes standard SQL, folding case with LOWER. It is the
+// dialect of the databases DialectOf doesn't know, and can be embedded in a
+// Dialect for such a database that only differs in some of its methods.
+type StandardDialect struct{}
+
+func (StandardDialect) Quote(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func (StandardDialect) Bool(value bool) string {
+	if value {
+		return "TRUE"
+	}
+
+	return "FALSE"
+}
+
+func (StandardDialect) RowValues() bool {
+	return true
+}
+
+func (StandardDialect) CompareFold(left clause.Expr, operator string, right clause.Expr) clause.Expr {
+	return joinSQL("LOWER(", left, ") "+operator+" LOWER(", right, ")")
+}
+
+func (StandardDialect) InFold(left clause.Expr, values []clause.Expr) clause.Expr {
+	lowered := make([]clause.Expr, len(values))
+	for i, value := range values {
+		lowered[i] = joinSQL("LOWER(", value, ")")
+	}
+
+	return joinSQL("LOWER(", left, ") IN (", joinList(lowered, ", "), ")")
+}
+
+func (StandardDialect) Match(expr clause.Expr, kind MatchKind, value string, fold bool) clause.Expr {
+	return Like(expr, kind, value, fold, escapeLike, `'\'`)
+}
+
+func (StandardDialect) Function(name string, args []clause.Expr) clause.Expr {
+	switch name {
+	case "tolower":
+		return joinSQL("LOWER(", args[0], ")")
+	case "toupper":
+		return joinSQL("UPPER(", args[0], ")")
+	case "trim":
+		return joinSQL("TRIM(", args[0], ")")
+	case "length":
+		return joinSQL("CHAR_LENGTH(", args[0], ")")
+	case "indexof":
+		// filters count from 0, SQL from 1
+		return joinSQL("(POSITION(", args[1], " IN ", args[0], ") - 1)")
+	case "concat":
+		return joinSQL("(", args[0], " || ", args[1], ")")
+	case "substring":
+		if len(args) == 3 {
+			return joinSQL("SUBSTRING(", args[0], " FROM ", args[1], " + 1 FOR ", args[2], ")")
+		}
+
+		return joinSQL("SUBSTRING(", args[0], " FROM ", args[1], " + 1)")
+	case "second":
+		// the seconds may have a fraction
+		return joinSQL("FLOOR(EXTRACT(SECOND FROM ", args[0], "))")
+	case "now":
+		return clause.Expr{SQL: "CURRENT_TIMESTAMP"}
+	}
+
+	if dateParts[name] {
+		return joinSQL("EXTRACT("+strings.ToUpper(name)+" FROM ", args[0], ")")
+	}
+
+	return clause.Expr{}
+}
+
+func (StandardDialect) Arithmetic(left clause.Expr, operator string, right clause.Expr, whole bool) clause.Expr {
+	return joinSQL(left, " "+arithmeticOperations[operator]+" ", right)
+}
+
+// sqliteDialect compares with the NOCASE collation, which unlike LOWER still
+// lets SQLite use an index of the column. LIKE ignores case in SQLite, so
+// case sensitive matches use GLOB instead. Booleans are stored as 1 and 0.
+type sqliteDialect struct {
+	StandardDialect
+}
+
+func (sqliteDialect) Quote(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+func (sqliteDialect) Bool(value bool) string {
+	if value {
+		return "1"
+	}
+
+	return "0"
+}
+
+func (sqliteDialect) CompareFold(left clause.Expr, operator string, right clause.Expr) clause.Expr {
+	return joinSQL(left, " "+operator+" ", right, " COLLATE NOCASE")
+}
+
+// InFold collates the left operand, as IN compares with its collation.
+func (sqliteDialect) InFold(left clause.Expr, values []clause.Expr) clause.Expr {
+	return joinSQL(left, " COLLATE NOCASE IN (", joinList(values, ", "), ")")
+}
+
+func (sqliteDialect) Match(expr clause.Expr, kind MatchKind, value string, fold bool) clause.Expr {
+	if fold {
+		return joinSQL(expr, " like ", bind(kind.Pattern(value, "%", escapeLike)), ` ESCAPE '\'`)
+	}
+
+	return joinSQL(expr, " GLOB ", bind(kind.Pattern(value, "*", escapeGlob)))
+}
+
+func (d sqliteDialect) Function(name string, args []clause.Expr) clause.Expr {
+	switch name {
+	case "length":
+		return joinSQL("LENGTH(", args[0], ")")
+	case "indexof":
+		return joinSQL("(instr(", args[0], ", ", args[1], ") - 1)")
+	case "substring":
+		if len(args) == 3 {
+			return joinSQL("substr(", args[0], ", ", args[1], " + 1, ", args[2], ")")
+		}
+
+		return joinSQL("substr(", args[0], ", ", args[1], " + 1)")
+	case "now":
+		// times are stored as text, which gorm writes in this form
+		return clause.Expr{SQL: "strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')"}
+	}
+
+	if format, ok := strftimeFormats[name]; ok {
+		return joinSQL("CAST(strftime('"+format+"', ", args[0], ") AS INTEGER)")
+	}
+
+	return d.StandardDialect.Function(name, args)
+}
+
+// strftimeFormats are the formats of strftime returning the date parts.
+var strftimeFormats = map[string]string{
+	"year":   "%Y",
+	"month":  "%m",
+	"day":    "%d",
+	"hour":   "%H",
+	"minute": "%M",
+	"second": "%S",
+}
+
+// postgresDialect matches with ILIKE, and LIKE when case matters.
+type postgresDialect struct {
+	StandardDialect
+}
+
+func (postgresDialect) Match(expr clause.Expr, kind MatchKind, value string, fold bool) clause.Expr {
+	pattern := bind(kind.Pattern(value, "%", escapeLike))
+	if fold {
+		return joinSQL(expr, " ilike ", pattern, ` ESCAPE '\'`)
+	}
+
+	return joinSQL(expr, " like ", pattern, ` ESCAPE '\'`)
+}
+
+func (d postgresDialect) Function(name string, args []clause.Expr) clause.Expr {
+	switch name {
+	case "length":
+		return joinSQL("LENGTH(", args[0], ")")
+	case "indexof":
+		return joinSQL("(strpos(", args[0], ", ", args[1], ") - 1)")
+	}
+
+	return d.StandardDialect.Function(name, args)
+}
+
+// mysqlDialect has a function for each date part. Backslashes escape in
+// MySQL strings, so the escape character of LIKE is written twice, || is or
+// rather than concatenation and / divides whole numbers into decimals.
+type mysqlDialect struct {
+	StandardDialect
+}
+
+func (mysqlDialect) Quote(name string) string {
+	return sqliteDialect{}.Quote(name)
+}
+
+func (mysqlDialect) Match(expr clause.Expr, kind MatchKind, value string, fold bool) clause.Expr {
+	return Like(expr, kind, value, fold, escapeLike, `'\\'`)
+}
+
+func (d mysqlDialect) Arithmetic(left clause.Expr, operator string, right clause.Expr, whole bool) clause.Expr {
+	if operator == "div" && whole {
+		return joinSQL(left, " DIV ", right)
+	}
+
+	return d.StandardDialect.Arithmetic(left, operator, right, whole)
+}
+
+func (d mysqlDialect) Function(name string, args []clause.Expr) clause.Expr {
+	if name == "concat" {
+		return joinSQL("CONCAT(", args[0], ", ", args[1], ")")
+	}
+
+	if dateParts[name] {
+		return joinSQL(strings.ToUpper(name)+"(", args[0], ")")
+	}
+
+	return d.StandardDialect.Function(name, args)
+}
+
+// sqlserverDialect quotes with brackets, which LIKE patterns also use for
+// sets of characters, stores booleans as bits and cannot compare row values.
+type sqlserverDialect struct {
+	StandardDialect
+}
+
+var sqlserverLikeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`, "[", `\[`)
+
+func (sqlserverDialect) Quote(name string) string {
+	return "[" + strings.ReplaceAll(name, "]", "]]") + "]"
+}
+
+func (sqlserverDialect) Bool(value bool) string {
+	return sqliteDialect{}.Bool(value)
+}
+
+func (sqlserverDialect) RowValues() bool {
+	return false
+}
+
+func (sqlserverDialect) Match(expr clause.Expr, kind MatchKind, value string, fold bool) clause.Expr {
+	return Like(expr, kind, value, fold, sqlserverLikeEscaper.Replace, `'\'`)
+}
+
+func (d sqlserverDialect) Function(name string, args []clause.Expr) clause.Expr {
+	switch name {
+	case "trim":
+		return joinSQL("LTRIM(RTRIM(", args[0], "))")
+	case "length":
+		return joinSQL("LEN(", args[0], ")")
+	case "indexof":
+		return joinSQL("(CHARINDEX(", args[1], ", ", args[0], ") - 1)")
+	case "concat":
+		return joinSQL("CONCAT(", args[0], ", ", args[1], ")")
+	case "substring":
+		// SUBSTRING requires a length, and one longer than the rest is cut
+		if len(args) == 3 {
+			return joinSQL("SUBSTRING(", args[0], ", ", args[1], " + 1, ", args[2], ")")
+		}
+
+		return joinSQL("SUBSTRING(", args[0], ", ", args[1], " + 1, LEN(", args[0], "))")
+	case "now":
+		// times are stored with their offset
+		return clause.Expr{SQL: "SYSDATETIMEOFFSET()"}
+	}
+
+	if dateParts[name] {
+		return joinSQL("DATEPART("+strings.ToUpper(name)+", ", args[0], ")")
+	}
+
+	return d.StandardDialect.Function(name, args)
+}
@@ -0,0 +1,305 @@
+package goatquery
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/utils/tests"
+)
+
+var (
+	nameColumn = clause.Expr{SQL: "name"}
+	valueVar   = bind("value")
+)
+
+func Test_DialectOf(t *testing.T) {
+	assert.Equal(t, sqliteDialect{}, DialectOf(DB))
+	assert.Equal(t, StandardDialect{}, DialectOf(&gorm.DB{Config: &gorm.Config{}}))
+
+	tests := map[string]Dialect{
+		"postgres":   postgresDialect{},
+		"mysql":      mysqlDialect{},
+		"sqlserver":  sqlserverDialect{},
+		"clickhouse": StandardDialect{},
+	}
+
+	for name, expected := range tests {
+		db, err := gorm.Open(namedDialector{name: name}, &gorm.Config{DryRun: true})
+		if assert.NoError(t, err) {
+			assert.Equal(t, expected, DialectOf(db), name)
+		}
+	}
+}
+
+func Test_JoinSQL(t *testing.T) {
+	expr := joinSQL("(", bind(1), " IN ", clause.Expr{SQL: "a + ?", Vars: []interface{}{2}}, ")")
+
+	assert.Equal(t, clause.Expr{SQL: "(? IN a + ?)", Vars: []interface{}{1, 2}}, expr)
+}
+
+func Test_DialectCaseInsensitive(t *testing.T) {
+	tests := []struct {
+		dialect Dialect
+		compare string
+		match   string
+	}{
+		{StandardDialect{}, "LOWER(name) = LOWER(?)", "LOWER(name) like LOWER(?) ESCAPE '\\'"},
+		{sqliteDialect{}, "name = ? COLLATE NOCASE", "name like ? ESCAPE '\\'"},
+		{postgresDialect{}, "LOWER(name) = LOWER(?)", "name ilike ? ESCAPE '\\'"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, clause.Expr{SQL: test.compare, Vars: []interface{}{"value"}}, test.dialect.CompareFold(nameColumn, "=", valueVar))
+		assert.Equal(t, clause.Expr{SQL: test.match, Vars: []interface{}{"%50\\%%"}}, test.dialect.Match(nameColumn, MatchContains, "50%", true))
+	}
+}
+
+func Test_DialectInFold(t *testing.T) {
+	values := []clause.Expr{bind("a"), bind("b")}
+
+	tests := []struct {
+		dialect  Dialect
+		expected string
+	}{
+		{StandardDialect{}, "LOWER(name) IN (LOWER(?), LOWER(?))"},
+		{sqliteDialect{}, "name COLLATE NOCASE IN (?, ?)"},
+		{postgresDialect{}, "LOWER(name) IN (LOWER(?), LOWER(?))"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, clause.Expr{SQL: test.expected, Vars: []interface{}{"a", "b"}}, test.dialect.InFold(nameColumn, values))
+	}
+}
+
+func Test_DialectCaseSensitiveMatch(t *testing.T) {
+	tests := []struct {
+		dialect Dialect
+		kind    MatchKind
+		match   string
+		pattern string
+	}{
+		{StandardDialect{}, MatchContains, "name like ? ESCAPE '\\'", "%50\\%%"},
+		{StandardDialect{}, MatchStartsWith, "name like ? ESCAPE '\\'", "50\\%%"},
+		{sqliteDialect{}, MatchContains, "name GLOB ?", "*50%[*]*"},
+		{sqliteDialect{}, MatchEndsWith, "name GLOB ?", "*50%[*]"},
+		{postgresDialect{}, MatchEndsWith, "name like ? ESCAPE '\\'", "%50\\%"},
+	}
+
+	for _, test := range tests {
+		value := "50%"
+		if _, ok := test.dialect.(sqliteDialect); ok {
+			value = "50%*"
+		}
+
+		assert.Equal(t, clause.Expr{SQL: test.match, Vars: []interface{}{test.pattern}}, test.dialect.Match(nameColumn, test.kind, value, false))
+	}
+}
+
+func Test_DialectFunctions(t *testing.T) {
+	start := bind(1)
+
+	tests := []struct {
+		dialect  Dialect
+		function string
+		args     []clause.Expr
+		expected string
+	}{
+		{StandardDialect{}, "length", []clause.Expr{nameColumn}, "CHAR_LENGTH(name)"},
+		{StandardDialect{}, "indexof", []clause.Expr{nameColumn, valueVar}, "(POSITION(? IN name) - 1)"},
+		{StandardDialect{}, "substring", []clause.Expr{nameColumn, start}, "SUBSTRING(name FROM ? + 1)"},
+		{StandardDialect{}, "concat", []clause.Expr{nameColumn, valueVar}, "(name || ?)"},
+		{sqliteDialect{}, "length", []clause.Expr{nameColumn}, "LENGTH(name)"},
+		{sqliteDialect{}, "indexof", []clause.Expr{nameColumn, valueVar}, "(instr(name, ?) - 1)"},
+		{sqliteDialect{}, "substring", []clause.Expr{nameColumn, start, start}, "substr(name, ? + 1, ?)"},
+		{sqliteDialect{}, "toupper", []clause.Expr{nameColumn}, "UPPER(name)"},
+		{postgresDialect{}, "indexof", []clause.Expr{nameColumn, valueVar}, "(strpos(name, ?) - 1)"},
+		{postgresDialect{}, "substring", []clause.Expr{nameColumn, start, start}, "SUBSTRING(name FROM ? + 1 FOR ?)"},
+		{StandardDialect{}, "year", []clause.Expr{nameColumn}, "EXTRACT(YEAR FROM name)"},
+		{StandardDialect{}, "second", []clause.Expr{nameColumn}, "FLOOR(EXTRACT(SECOND FROM name))"},
+		{sqliteDialect{}, "minute", []clause.Expr{nameColumn}, "CAST(strftime('%M', name) AS INTEGER)"},
+		{postgresDialect{}, "month", []clause.Expr{nameColumn}, "EXTRACT(MONTH FROM name)"},
+		{mysqlDialect{}, "day", []clause.Expr{nameColumn}, "DAY(name)"},
+		{mysqlDialect{}, "length", []clause.Expr{nameColumn}, "CHAR_LENGTH(name)"},
+	}
+
+	for _, test := range tests {
+		expr := test.dialect.Function(test.function, test.args)
+
+		assert.Equal(t, test.expected, expr.SQL, test.function)
+	}
+}
+
+// namedDialector writes SQL without a database, with the name of the
+// dialector of the database whose dialect is tested.
+type namedDialector struct {
+	tests.DummyDialector
+	name string
+}
+
+func (d namedDialector) Name() string {
+	return d.name
+}
+
+// Ranking has columns that have to be quoted.
+type Ranking struct {
+	Id    int    `json:"id"`
+	Order int    `json:"order"`
+	Title string `gorm:"column:Title" json:"title"`
+}
+
+// drySQL returns the SQL and vars of query for model, written for the
+// database with the dialector name.
+func drySQL(t *testing.T, name string, model interface{}, query Query) (string, []interface{}) {
+	db, err := gorm.Open(namedDialector{name: name}, &gorm.Config{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, _, _, err := ApplyWith(db.Model(model), query, model)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stmt := res.Find(model).Statement
+
+	return stmt.SQL.String(), stmt.Vars
+}
+
+func Test_DialectGoldenSQL(t *testing.T) {
+	tests := []struct {
+		dialector string
+		filter    string
+		where     string
+		vars      []interface{}
+	}{
+		{"sqlite", "firstname eq 'goat'", "firstname = ? COLLATE NOCASE", []interface{}{"goat"}},
+		{"sqlite", "firstname contains '50%_[a]'", "firstname like ? ESCAPE '\\'", []interface{}{"%50\\%\\_[a]%"}},
+		{"sqlite", "firstname in ('a', 'b')", "firstname COLLATE NOCASE IN (?, ?)", []interface{}{"a", "b"}},
+		{"sqlite", "contributor eq true and startswith(lastname, 'q') eq false", "contributor = 1 and NOT (lastname like ? ESCAPE '\\')", []interface{}{"q%"}},
+		{"sqlite", "year(createdAt) eq 2024 and length(concat(firstname, lastname)) gt 3", "CAST(strftime('%Y', created_at) AS INTEGER) = ? and LENGTH((firstname || lastname)) > ?", []interface{}{2024, 3}},
+		{"sqlite", "manager/firstname eq 'goat'", "EXISTS (SELECT 1 FROM users users_1 WHERE users_1.id = users.manager_id AND users_1.firstname = ? COLLATE NOCASE)", []interface{}{"goat"}},
+		{"sqlite", "age div 2 eq 1 and age mod 2 eq 1", "age / ? = ? and age % ? = ?", []interface{}{2, 1, 2, 1}},
+		{"sqlite", "createdAt lt now()", "created_at < strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')", nil},
+
+		{"postgres", "firstname eq 'goat'", "LOWER(firstname) = LOWER(?)", []interface{}{"goat"}},
+		{"postgres", "firstname contains '50%_[a]'", "firstname ilike ? ESCAPE '\\'", []interface{}{"%50\\%\\_[a]%"}},
+		{"postgres", "firstname in ('a', 'b')", "LOWER(firstname) IN (LOWER(?), LOWER(?))", []interface{}{"a", "b"}},
+		{"postgres", "contributor eq true and startswith(lastname, 'q') eq false", "contributor = TRUE and NOT (lastname ilike ? ESCAPE '\\')", []interface{}{"q%"}},
+		{"postgres", "year(createdAt) eq 2024 and length(concat(firstname, lastname)) gt 3", "EXTRACT(YEAR FROM created_at) = ? and LENGTH((firstname || lastname)) > ?", []interface{}{2024, 3}},
+		{"postgres", "manager/firstname eq 'goat'", "EXISTS (SELECT 1 FROM users users_1 WHERE users_1.id = users.manager_id AND LOWER(users_1.firstname) = LOWER(?))", []interface{}{"goat"}},
+		{"postgres", "age div 2 eq 1 and age mod 2 eq 1", "age / ? = ? and age % ? = ?", []interface{}{2, 1, 2, 1}},
+		{"postgres", "createdAt lt now()", "created_at < CURRENT_TIMESTAMP", nil},
+
+		{"mysql", "firstname eq 'goat'", "LOWER(firstname) = LOWER(?)", []interface{}{"goat"}},
+		{"mysql", "firstname contains '50%_[a]'", "LOWER(firstname) like LOWER(?) ESCAPE '\\\\'", []interface{}{"%50\\%\\_[a]%"}},
+		{"mysql", "firstname in ('a', 'b')", "LOWER(firstname) IN (LOWER(?), LOWER(?))", []interface{}{"a", "b"}},
+		{"mysql", "contributor eq true and startswith(lastname, 'q') eq false", "contributor = TRUE and NOT (LOWER(lastname) like LOWER(?) ESCAPE '\\\\')", []interface{}{"q%"}},
+		{"mysql", "year(createdAt) eq 2024 and length(concat(firstname, lastname)) gt 3", "YEAR(created_at) = ? and CHAR_LENGTH(CONCAT(firstname, lastname)) > ?", []interface{}{2024, 3}},
+		{"mysql", "manager/firstname eq 'goat'", "EXISTS (SELECT 1 FROM users users_1 WHERE users_1.id = users.manager_id AND LOWER(users_1.firstname) = LOWER(?))", []interface{}{"goat"}},
+		{"mysql", "age div 2 eq 1 and age mod 2 eq 1", "age DIV ? = ? and age % ? = ?", []interface{}{2, 1, 2, 1}},
+		{"mysql", "age div 2.5 gt 1 and (age add 1) div 2 lt 5", "age / ? > ? and (age + ?) DIV ? < ?", []interface{}{2.5, 1, 1, 2, 5}},
+		{"mysql", "createdAt lt now()", "created_at < CURRENT_TIMESTAMP", nil},
+
+		{"sqlserver", "firstname eq 'goat'", "LOWER(firstname) = LOWER(?)", []interface{}{"goat"}},
+		{"sqlserver", "firstname contains '50%_[a]'", "LOWER(firstname) like LOWER(?) ESCAPE '\\'", []interface{}{"%50\\%\\_\\[a]%"}},
+		{"sqlserver", "firstname in ('a', 'b')", "LOWER(firstname) IN (LOWER(?), LOWER(?))", []interface{}{"a", "b"}},
+		{"sqlserver", "contributor eq true and startswith(lastname, 'q') eq false", "contributor = 1 and NOT (LOWER(lastname) like LOWER(?) ESCAPE '\\')", []interface{}{"q%"}},
+		{"sqlserver", "year(createdAt) eq 2024 and length(concat(firstname, lastname)) gt 3", "DATEPART(YEAR, created_at) = ? and LEN(CONCAT(firstname, lastname)) > ?", []interface{}{2024, 3}},
+		{"sqlserver", "substring(firstname, 1) eq 'oat'", "LOWER(SUBSTRING(firstname, ? + 1, LEN(firstname))) = LOWER(?)", []interface{}{1, "oat"}},
+		{"sqlserver", "age div 2 eq 1 and age mod 2 eq 1", "age / ? = ? and age % ? = ?", []interface{}{2, 1, 2, 1}},
+		{"sqlserver", "createdAt lt now()", "created_at < SYSDATETIMEOFFSET()", nil},
+
+		{"clickhouse", "firstname contains '50%_[a]'", "LOWER(firstname) like LOWER(?) ESCAPE '\\'", []interface{}{"%50\\%\\_[a]%"}},
+		{"clickhouse", "contributor eq true and startswith(lastname, 'q') eq false", "contributor = TRUE and NOT (LOWER(lastname) like LOWER(?) ESCAPE '\\')", []interface{}{"q%"}},
+		{"clickhouse", "year(createdAt) eq 2024 and length(concat(firstname, lastname)) gt 3", "EXTRACT(YEAR FROM created_at) = ? and CHAR_LENGTH((firstname || lastname)) > ?", []interface{}{2024, 3}},
+		{"clickhouse", "age div 2 eq 1 and age mod 2 eq 1", "age / ? = ? and age % ? = ?", []interface{}{2, 1, 2, 1}},
+		{"clickhouse", "createdAt lt now()", "created_at < CURRENT_TIMESTAMP", nil},
+	}
+
+	for _, test := range tests {
+		sql, vars := drySQL(t, test.dialector, &[]User{}, Query{Filter: test.filter})
+
+		assert.Equal(t, "SELECT * FROM `users` WHERE "+test.where, sql, test.dialector, test.filter)
+		assert.Equal(t, fmt.Sprint(test.vars), fmt.Sprint(vars), test.dialector, test.filter)
+	}
+}
+
+func Test_DialectQuotesIdentifiers(t *testing.T) {
+	tests := map[string]string{
+		"sqlite":     "`order` > ? and `Title` = ? COLLATE NOCASE",
+		"postgres":   `"order" > ? and LOWER("Title") = LOWER(?)`,
+		"mysql":      "`order` > ? and LOWER(`Title`) = LOWER(?)",
+		"sqlserver":  "[order] > ? and LOWER([Title]) = LOWER(?)",
+		"clickhouse": `"order" > ? and LOWER("Title") = LOWER(?)`,
+	}
+
+	for dialector, where := range tests {
+		sql, _ := drySQL(t, dialector, &[]Ranking{}, Query{Filter: "order gt 1 and title eq 'a'"})
+
+		assert.Equal(t, "SELECT * FROM `rankings` WHERE "+where, sql, dialector)
+	}
+}
+
+func Test_QuoteIdentifier(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"first_name", "first_name"},
+		{"address2", "address2"},
+		{"user", "`user`"},
+		{"FirstName", "`FirstName`"},
+		{"2fa", "`2fa`"},
+		{"we`ird", "`we``ird`"},
+		{"public.order", "public.`order`"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, quoteIdentifier(sqliteDialect{}, test.name))
+	}
+
+	assert.Equal(t, "[a]]b]", sqlserverDialect{}.Quote("a]b"))
+	assert.Equal(t, `"a""b"`, postgresDialect{}.Quote(`a"b`))
+}
+
+func Test_DialectCursorSQL(t *testing.T) {
+	cursor := testCursor(`{"k":[1,"a",2]}`)
+
+	tests := map[string]string{
+		"sqlite":    "SELECT * FROM `rankings` WHERE (`order`, `Title`, id) > (?, ?, ?) ORDER BY `order`,`Title`,`id` LIMIT ?",
+		"sqlserver": "SELECT * FROM `rankings` WHERE [order] > ? OR ([order] = ? AND [Title] > ?) OR ([order] = ? AND [Title] = ? AND id > ?) ORDER BY `order`,`Title`,`id` LIMIT ?",
+	}
+
+	for dialector, expected := range tests {
+		sql, _ := drySQL(t, dialector, &[]Ranking{}, Query{Top: 2, OrderBy: "order, title", Cursor: cursor})
+
+		assert.Equal(t, expected, sql, dialector)
+	}
+}
+
+func Test_QueryWithCursorQuotesColumns(t *testing.T) {
+	assert.NoError(t, DB.AutoMigrate(&Ranking{}))
+	assert.NoError(t, DB.Create(&[]Ranking{{Id: 1, Order: 1, Title: "a"}, {Id: 2, Order: 1, Title: "b"}, {Id: 3, Order: 2, Title: "c"}}).Error)
+
+	var titles []string
+	for cursor := CursorStart; cursor != ""; {
+		query := Query{Top: 2, OrderBy: "order desc, title", Cursor: cursor}
+
+		var rankings []Ranking
+		res, _, err := Apply(DB.Model(&Ranking{}), query, nil, nil, &rankings)
+		if !assert.NoError(t, err) || !assert.NoError(t, res.Find(&rankings).Error) {
+			return
+		}
+
+		for _, ranking := range rankings {
+			titles = append(titles, ranking.Title)
+		}
+
+		cursor = BuildPagedResponse(rankings, query, nil).NextCursor
+	}
+
+	assert.Equal(t, []string{"c", "a", "b"}, titles)
+}
@@ -0,0 +1,78 @@
+package goatquery
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// The kinds of problems a query can have. A *QueryError wraps one of them, so
+// they can be checked with errors.Is.
+var (
+	ErrTopExceedsMax      = errors.New("top exceeds the maximum")
+	ErrInvalidFilter      = errors.New("invalid filter")
+	ErrInvalidOrderBy     = errors.New("invalid orderby")
+	ErrInvalidSelect      = errors.New("invalid select")
+	ErrInvalidExpand      = errors.New("invalid expand")
+	ErrInvalidCursor      = errors.New("invalid cursor")
+	ErrUnknownProperty    = errors.New("unknown property")
+	ErrPropertyNotAllowed = errors.New("property not allowed")
+)
+
+// QueryError describes a problem with one of the query parameters.
+type QueryError struct {
+	// Err is the kind of problem, e.g. ErrUnknownProperty.
+	Err error
+	// Clause is the query parameter the problem was found in, e.g. "Filter".
+	Clause string
+	// Property is the offending property, if any.
+	Property string
+	// Position is the offset of the problem within the clause.
+	Position int
+	// Status is the HTTP status code suggested for the response.
+	Status  int
+	Message string
+}
+
+func (e *QueryError) Error() string {
+	return e.Message
+}
+
+func (e *QueryError) Unwrap() error {
+	return e.Err
+}
+
+func newQueryError(kind error, clause string, position int, format string, args ...interface{}) *QueryError {
+	return &QueryError{
+		Err:      kind,
+		Clause:   clause,
+		Position: position,
+		Status:   http.StatusBadRequest,
+		Message:  fmt.Sprintf(format, args...),
+	}
+}
+
+func topExceedsMaxError() error {
+	return newQueryError(ErrTopExceedsMax, "Top", 0, "The value supplied for the query parameter 'Top' was greater than the maximum top allowed for this resource")
+}
+
+func unknownPropertyError(clause string, property string, position int) error {
+	err := newQueryError(ErrUnknownProperty, clause, position, "The property '%s' supplied for the query parameter '%s' does not exist on this resource", property, clause)
+	err.Property = property
+
+	return err
+}
+
+func invalidFilterError(position int, format string, args ...interface{}) error {
+	return newQueryError(ErrInvalidFilter, "Filter", position, format, args...)
+}
+
+// filterSyntaxError converts an error from ParseFilter into a *QueryError.
+func filterSyntaxError(err error) error {
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		return err
+	}
+
+	return invalidFilterError(syntaxErr.Position, "The query parameter 'Filter' is not valid, %s at position %d", syntaxErr.Message, syntaxErr.Position)
+}
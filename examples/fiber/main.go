@@ -3,6 +3,7 @@ package main
 import (
 	"errors"
 	"fmt"
+	"net/url"
 
 	"github.com/brianvoe/gofakeit/v6"
 	"github.com/gofiber/fiber/v2"
@@ -62,6 +63,10 @@ type UserDto struct {
 	Permissions []UserPermission `json:"permissions" gorm:"foreignKey:UserId"`
 }
 
+func (UserDto) ExpandableProperties() []string {
+	return []string{"address", "permissions"}
+}
+
 var DB *gorm.DB
 
 func main() {
@@ -126,25 +131,30 @@ func getUsers(c *fiber.Ctx) error {
 		Select:  c.Query("select"),
 		Search:  c.Query("search"),
 		Filter:  c.Query("filter"),
+		Expand:  c.Query("expand"),
+		Cursor:  c.Query("cursor"),
 	}
 
 	var users []UserDto
-	res, count, err := goatquery.Apply(GetAllUsers(DB), query, nil, nil, &users)
+	// the returned query has the page size the options applied
+	res, query, count, err := goatquery.ApplyWith(GetAllUsers(DB), query, &users, goatquery.WithMaxTop(100), goatquery.WithSearch(UserDtoSearch))
 	if err != nil {
-		return c.Status(400).JSON(goatquery.QueryErrorResponse{Status: 400, Message: err.Error()})
+		response := goatquery.BuildErrorResponse(err)
+		return c.Status(int(response.Status)).JSON(response)
 	}
 
 	if err := res.Find(&users).Error; err != nil {
 		return c.Status(400).JSON(goatquery.QueryErrorResponse{Status: 400, Message: err.Error()})
 	}
 
-	response := goatquery.BuildPagedResponse(users, query, count)
+	requestURL, _ := url.Parse(c.BaseURL() + c.OriginalURL())
+	response := goatquery.BuildPagedResponse(users, query, count).WithNextLink(requestURL, query)
 
 	return c.JSON(response)
 }
 
 func GetAllUsers(db *gorm.DB) *gorm.DB {
-	return db.Model(&User{}).Where("is_deleted <> ?", true).Preload("Address").Preload("Permissions")
+	return db.Model(&User{}).Where("is_deleted <> ?", true)
 }
 
 func UserDtoSearch(db *gorm.DB, searchTerm string) *gorm.DB {
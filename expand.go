@@ -0,0 +1,400 @@
+package goatquery
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// Expandable is implemented by models that allow clients to load their
+// relations with Query.Expand.
+type Expandable interface {
+	// ExpandableProperties returns the json names of the relations that can
+	// be expanded.
+	ExpandableProperties() []string
+}
+
+// expandItem is one relation of the Expand query parameter along with its options.
+type expandItem struct {
+	Property string
+	Position int
+	Options  Query
+	Expanded []expandItem
+	// offsets holds the offset of the value of each option within the Expand
+	// query parameter, so errors in options can point at the right place.
+	offsets map[string]int
+}
+
+// preload loads a relation of the model with the options it was expanded with.
+type preload struct {
+	Item     expandItem
+	Field    string
+	Relation *schema.Relationship
+	Scope    func(*gorm.DB) *gorm.DB
+}
+
+func findExpandItem(items []expandItem, property string) (expandItem, bool) {
+	for _, item := range items {
+		if item.Property == property {
+			return item, true
+		}
+	}
+
+	return expandItem{}, false
+}
+
+func invalidExpandError(position int, format string, args ...interface{}) error {
+	return newQueryError(ErrInvalidExpand, "Expand", position, format, args...)
+}
+
+// parseExpand parses a comma separated list of "property[(options)]" items,
+// where the options are separated by semicolons. offset is the position of
+// input within the Expand query parameter.
+func parseExpand(input string, offset int) ([]expandItem, error) {
+	var items []expandItem
+
+	for _, part := range splitTopLevel(input, ',') {
+		name, rest, _ := strings.Cut(part.Value, "(")
+
+		item := expandItem{
+			Property: strings.TrimSpace(name),
+			Position: offset + part.Position,
+			offsets:  map[string]int{},
+		}
+
+		if item.Property == "" {
+			return nil, invalidExpandError(item.Position, "The query parameter 'Expand' is not valid, expected a property at position %d", item.Position)
+		}
+
+		if strings.Contains(part.Value, "(") {
+			optionsOffset := offset + part.Position + len(name) + 1
+			if !strings.HasSuffix(rest, ")") {
+				return nil, invalidExpandError(optionsOffset-1, "The query parameter 'Expand' is not valid, expected ')' to close '(' at position %d", optionsOffset-1)
+			}
+
+			if err := parseExpandOptions(&item, strings.TrimSuffix(rest, ")"), optionsOffset); err != nil {
+				return nil, err
+			}
+		}
+
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+func parseExpandOptions(item *expandItem, input string, offset int) error {
+	for _, option := range splitTopLevel(input, ';') {
+		key, value, ok := strings.Cut(option.Value, "=")
+		position := offset + option.Position
+		name := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(key), "$"))
+
+		if !ok {
+			return invalidExpandError(position, "The query parameter 'Expand' is not valid, expected '$option=value' at position %d", position)
+		}
+
+		valuePosition := position + len(key) + 1
+		value = strings.TrimSpace(value)
+		item.offsets[name] = valuePosition
+
+		switch name {
+		case "filter":
+			item.Options.Filter = value
+		case "orderby":
+			item.Options.OrderBy = value
+		case "select":
+			item.Options.Select = value
+		case "top":
+			top, err := strconv.Atoi(value)
+			if err != nil || top < 0 {
+				return invalidExpandError(valuePosition, "The value '%s' supplied for the option '$top' of the expanded property '%s' is not a positive integer", value, item.Property)
+			}
+
+			item.Options.Top = top
+		case "expand":
+			expanded, err := parseExpand(value, valuePosition)
+			if err != nil {
+				return err
+			}
+
+			item.Options.Expand = value
+			item.Expanded = expanded
+		default:
+			return invalidExpandError(position, "The option '%s' of the expanded property '%s' is not supported", strings.TrimSpace(key), item.Property)
+		}
+	}
+
+	return nil
+}
+
+// splitTopLevel splits input on sep, ignoring separators that are quoted or
+// inside parentheses.
+func splitTopLevel(input string, sep byte) []listItem {
+	var items []listItem
+
+	depth := 0
+	quoted := false
+	start := 0
+
+	for i := 0; i <= len(input); i++ {
+		if i < len(input) {
+			switch char := input[i]; {
+			case char == '\'':
+				quoted = !quoted
+				continue
+			case quoted:
+				continue
+			case char == '(':
+				depth++
+				continue
+			case char == ')':
+				depth--
+				continue
+			case char != sep || depth > 0:
+				continue
+			}
+		}
+
+		item := input[start:i]
+		trimmed := strings.TrimSpace(item)
+		items = append(items, listItem{Value: trimmed, Position: start + strings.Index(item, trimmed)})
+		start = i + 1
+	}
+
+	return items
+}
+
+// buildExpand resolves the relations of the Expand query parameter to the
+// preloads that load them.
+func buildExpand(db *gorm.DB, expand string, modelType reflect.Type, filter filterOptions) ([]preload, error) {
+	items, err := parseExpand(expand, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return buildPreloads(db, items, modelType, filter)
+}
+
+func buildPreloads(db *gorm.DB, items []expandItem, modelType reflect.Type, filter filterOptions) ([]preload, error) {
+	properties, err := modelPropertiesOf(db, modelType)
+	if err != nil {
+		return nil, err
+	}
+
+	var preloads []preload
+	for _, item := range items {
+		p, ok := properties.lookup(item.Property)
+		if !ok {
+			return nil, unknownPropertyError("Expand", item.Property, item.Position)
+		}
+
+		rel := p.Relation
+		if rel == nil {
+			return nil, invalidExpandError(item.Position, "The property '%s' supplied for the query parameter 'Expand' is not a relation", item.Property)
+		}
+
+		// an expanded relation is part of the response
+		if !p.allows(capSelect) {
+			return nil, propertyNotAllowedError("Expand", item.Property, item.Position, capSelect)
+		}
+
+		if !isExpandable(modelType, item.Property) {
+			return nil, invalidExpandError(item.Position, "The property '%s' supplied for the query parameter 'Expand' cannot be expanded on this resource", item.Property)
+		}
+
+		scope, err := buildPreloadScope(db, item, rel, filter)
+		if err != nil {
+			return nil, err
+		}
+
+		preloads = append(preloads, preload{Item: item, Field: p.Field.Name, Relation: rel, Scope: scope})
+	}
+
+	return preloads, nil
+}
+
+// buildPreloadScope validates the options of item and returns the function
+// applying them to the query loading the related rows.
+func buildPreloadScope(db *gorm.DB, item expandItem, rel *schema.Relationship, filter filterOptions) (func(*gorm.DB) *gorm.DB, error) {
+	options := item.Options
+	modelType := rel.FieldSchema.ModelType
+	properties := propertiesOf(rel.FieldSchema, db.NamingStrategy)
+
+	// the related rows are queried on their own, not through the model of db
+	db = db.Session(&gorm.Session{NewDB: true})
+
+	var (
+		where   string
+		args    []interface{}
+		terms   []orderByTerm
+		columns []string
+		err     error
+	)
+
+	if options.Filter != "" {
+		if where, args, err = buildFilter(db, options.Filter, modelType, filter); err != nil {
+			return nil, expandOptionError(item, "filter", err)
+		}
+	}
+
+	if options.OrderBy != "" {
+		if terms, err = parseOrderBy(options.OrderBy, properties); err != nil {
+			return nil, expandOptionError(item, "orderby", err)
+		}
+	}
+
+	if options.Top > 0 && (rel.Type != schema.HasMany || len(rel.FieldSchema.PrimaryFields) != 1) {
+		return nil, invalidExpandError(item.offsets["top"], "The option '$top' of the expanded property '%s' is only supported on one to many relations", item.Property)
+	}
+
+	preloads, err := buildPreloads(db, item.Expanded, modelType, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	if options.Select != "" {
+		if columns, err = selectColumns(properties, options.Select); err != nil {
+			return nil, expandOptionError(item, "select", err)
+		}
+
+		// the related rows are matched up with their owners by their keys
+		columns = appendColumns(columns, relationKeys(rel, rel.FieldSchema)...)
+		columns = appendKeyColumns(columns, preloads)
+	}
+
+	return func(tx *gorm.DB) *gorm.DB {
+		if where != "" {
+			tx = tx.Where(where, args...)
+		}
+
+		for _, term := range terms {
+			tx = tx.Order(term.clause())
+		}
+
+		if columns != nil {
+			tx = tx.Select(columns)
+		}
+
+		if options.Top > 0 {
+			topWhere, topArgs := topPerOwner(filter.dialectFor(db), rel, terms, where, args, options.Top)
+			tx = tx.Where(topWhere, topArgs...)
+		}
+
+		for _, p := range preloads {
+			tx = tx.Preload(p.Field, p.Scope)
+		}
+
+		return tx
+	}, nil
+}
+
+// topPerOwner returns the condition keeping the first top related rows of
+// every owner of rel, in the order of terms and then by primary key, where
+// where and args filter the related rows. The related rows of every owner are
+// loaded by one query, so a LIMIT would apply to all of them together.
+func topPerOwner(d Dialect, rel *schema.Relationship, terms []orderByTerm, where string, args []interface{}, top int) (string, []interface{}) {
+	key := quoteIdentifier(d, rel.FieldSchema.PrioritizedPrimaryField.DBName)
+
+	var owners []string
+	for _, ref := range rel.References {
+		owners = append(owners, quoteIdentifier(d, ref.ForeignKey.DBName))
+	}
+
+	var order []string
+	for _, term := range terms {
+		column := quoteIdentifier(d, term.Column)
+		if term.Desc {
+			column += " DESC"
+		}
+
+		order = append(order, column)
+	}
+
+	order = append(order, key)
+
+	from := quoteIdentifier(d, rel.FieldSchema.Table)
+	if where != "" {
+		from += " WHERE " + where
+	}
+
+	sql := fmt.Sprintf("%s IN (SELECT %s FROM (SELECT %s, ROW_NUMBER() OVER (PARTITION BY %s ORDER BY %s) AS goatquery_row FROM %s) goatquery_ranked WHERE goatquery_row <= ?)",
+		key, key, key, strings.Join(owners, ", "), strings.Join(order, ", "), from)
+
+	return sql, append(append([]interface{}{}, args...), top)
+}
+
+// expandOptionError moves an error in an option of item into the Expand query parameter.
+func expandOptionError(item expandItem, option string, err error) error {
+	var queryErr *QueryError
+	if !errors.As(err, &queryErr) || queryErr.Clause == "Expand" {
+		return err
+	}
+
+	moved := *queryErr
+	moved.Clause = "Expand"
+	moved.Position += item.offsets[option]
+	moved.Message = fmt.Sprintf("%s (in the option '$%s' of the expanded property '%s')", queryErr.Message, option, item.Property)
+
+	return &moved
+}
+
+func isExpandable(modelType reflect.Type, property string) bool {
+	expandable, ok := reflect.New(modelType).Interface().(Expandable)
+	if !ok {
+		return false
+	}
+
+	for _, p := range expandable.ExpandableProperties() {
+		if p == property {
+			return true
+		}
+	}
+
+	return false
+}
+
+// relationKeys returns the columns of sch that rel uses to match rows.
+func relationKeys(rel *schema.Relationship, sch *schema.Schema) []string {
+	var columns []string
+	for _, ref := range rel.References {
+		if ref.PrimaryKey != nil && ref.PrimaryKey.Schema == sch {
+			columns = append(columns, ref.PrimaryKey.DBName)
+		}
+
+		if ref.ForeignKey != nil && ref.ForeignKey.Schema == sch {
+			columns = append(columns, ref.ForeignKey.DBName)
+		}
+	}
+
+	return columns
+}
+
+// appendKeyColumns adds the columns of the owning model needed to load
+// preloads to columns.
+func appendKeyColumns(columns []string, preloads []preload) []string {
+	for _, p := range preloads {
+		columns = appendColumns(columns, relationKeys(p.Relation, p.Relation.Schema)...)
+	}
+
+	return columns
+}
+
+func appendColumns(columns []string, extra ...string) []string {
+	for _, column := range extra {
+		found := false
+		for _, c := range columns {
+			found = found || c == column
+		}
+
+		if !found {
+			columns = append(columns, column)
+		}
+	}
+
+	return columns
+}
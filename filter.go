@@ -0,0 +1,690 @@
+package goatquery
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// filterBuilder translates a parsed filter into a SQL where clause for a model.
+type filterBuilder struct {
+	db      *gorm.DB
+	dialect Dialect
+	options filterOptions
+	scope   *filterScope
+	sql     strings.Builder
+	args    []interface{}
+}
+
+// filterScope is the model that properties are resolved against. Navigating a
+// relation opens a subquery with the related model as its scope.
+type filterScope struct {
+	properties *modelProperties
+	// table is the name or alias the table of the scope is referred to by.
+	table string
+	// qualify prefixes columns with the table, which is needed in subqueries.
+	qualify bool
+	// variable is the name of the lambda variable referring to the scope.
+	variable string
+	parent   *filterScope
+}
+
+// buildFilter parses filter and translates it into a where clause for modelType.
+func buildFilter(db *gorm.DB, filter string, modelType reflect.Type, options filterOptions) (string, []interface{}, error) {
+	node, err := ParseFilter(filter)
+	if err != nil {
+		return "", nil, filterSyntaxError(err)
+	}
+
+	builder, err := newFilterBuilder(db, modelType, options)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return builder.build(node)
+}
+
+func newFilterBuilder(db *gorm.DB, modelType reflect.Type, options filterOptions) (*filterBuilder, error) {
+	properties, err := modelPropertiesOf(db, modelType)
+	if err != nil {
+		return nil, err
+	}
+
+	return &filterBuilder{
+		db:      db,
+		dialect: options.dialectFor(db),
+		options: options,
+		scope:   &filterScope{properties: properties, table: queryTable(db, properties.schema)},
+	}, nil
+}
+
+// fold reports whether strings are compared ignoring case, which is up to the
+// first of properties, the properties compared, that sets it.
+func (b *filterBuilder) fold(properties ...*property) bool {
+	for _, p := range properties {
+		switch {
+		case p == nil:
+			continue
+		case p.Case == caseSensitive:
+			return false
+		case p.Case == caseInsensitive:
+			return true
+		}
+	}
+
+	return !b.options.caseSensitive
+}
+
+// column returns the column of property as it is referred to in scope.
+// Columns are qualified with the table in subqueries, or when qualify is set.
+func (b *filterBuilder) column(scope *filterScope, property *property, qualify bool) string {
+	if !scope.qualify && !qualify {
+		return b.quote(property.Column)
+	}
+
+	return b.quote(scope.table) + "." + b.quote(property.Column)
+}
+
+// quote quotes the identifier name when the dialect requires it.
+func (b *filterBuilder) quote(name string) string {
+	return quoteIdentifier(b.dialect, name)
+}
+
+// build returns the where clause for node with a "?" placeholder for every
+// literal, along with the values to bind to them.
+func (b *filterBuilder) build(node Node) (string, []interface{}, error) {
+	if err := b.write(node); err != nil {
+		return "", nil, err
+	}
+
+	return b.sql.String(), b.args, nil
+}
+
+func (b *filterBuilder) write(node Node) error {
+	switch n := node.(type) {
+	case *LogicalNode:
+		if err := b.writeGrouped(n.Left, n); err != nil {
+			return err
+		}
+
+		b.sql.WriteString(fmt.Sprintf(" %s ", n.Operator))
+
+		return b.writeGrouped(n.Right, n)
+	case *NotNode:
+		b.sql.WriteString("NOT (")
+		if err := b.write(n.Operand); err != nil {
+			return err
+		}
+		b.sql.WriteString(")")
+
+		return nil
+	case *ComparisonNode, *CallNode:
+		return b.writePredicate(n)
+	case *LambdaNode:
+		return b.writeLambda(n)
+	}
+
+	return invalidFilterError(node.Pos(), "The expression '%s' at position %d is not supported", node, node.Pos())
+}
+
+// writeGrouped writes child, wrapped in parentheses when the SQL precedence
+// would otherwise differ from the filter's.
+func (b *filterBuilder) writeGrouped(child Node, parent Node) error {
+	if !needsGrouping(child, parent) {
+		return b.write(child)
+	}
+
+	b.sql.WriteString("(")
+	if err := b.write(child); err != nil {
+		return err
+	}
+	b.sql.WriteString(")")
+
+	return nil
+}
+
+// writePredicate writes a comparison, or a call of a function returning a
+// boolean, inside EXISTS subqueries for the relations navigated to reach its
+// properties.
+func (b *filterBuilder) writePredicate(node Node) error {
+	current := b.scope
+	defer func() { b.scope = current }()
+
+	owner, path, property, err := b.navigation(node)
+	if err != nil {
+		return err
+	}
+
+	scope, opened, err := b.openRelations(owner, path, property, "")
+	if err != nil {
+		return err
+	}
+
+	condition, err := b.condition(node, scope)
+	if err != nil {
+		return err
+	}
+
+	b.sql.WriteString(condition.SQL)
+	b.args = append(b.args, condition.Vars...)
+	b.sql.WriteString(strings.Repeat(")", opened))
+
+	return nil
+}
+
+// navigation returns the scope and the path of relations the properties of
+// node are reached through, along with the first property reached through
+// them. The properties of a predicate can't be reached through different
+// relations, as it is written inside a single subquery.
+func (b *filterBuilder) navigation(node Node) (*filterScope, []string, *PropertyNode, error) {
+	var (
+		owner     = b.scope
+		path      []string
+		navigated *PropertyNode
+		err       error
+	)
+
+	walkOperands(node, func(property *PropertyNode) {
+		scope, rest := b.resolve(property)
+		if len(rest) == 0 || err != nil {
+			return
+		}
+
+		if navigated == nil {
+			owner, path, navigated = scope, rest, property
+			return
+		}
+
+		if scope != owner || strings.Join(rest, "/") != strings.Join(path, "/") {
+			err = invalidFilterError(property.Position, "The properties '%s' and '%s' at positions %d and %d are reached through different relations and cannot be used in the same condition", navigated, property, navigated.Position, property.Position)
+		}
+	})
+
+	return owner, path, navigated, err
+}
+
+// writeLambda writes an any or all operator as a correlated EXISTS subquery
+// over the rows of the collection.
+func (b *filterBuilder) writeLambda(n *LambdaNode) error {
+	current := b.scope
+	defer func() { b.scope = current }()
+
+	owner, path := b.resolve(n.Collection)
+
+	path = append(append([]string{}, path...), n.Collection.Name)
+
+	scope, opened, err := b.openRelations(owner, path, n.Collection, n.Function)
+	if err != nil {
+		return err
+	}
+
+	scope.variable = n.Variable
+
+	switch {
+	case n.Function == "all":
+		// every row matches when no row doesn't
+		b.sql.WriteString(" AND NOT (")
+		if err := b.write(n.Predicate); err != nil {
+			return err
+		}
+		b.sql.WriteString(")")
+	case n.Predicate != nil:
+		b.sql.WriteString(" AND (")
+		if err := b.write(n.Predicate); err != nil {
+			return err
+		}
+		b.sql.WriteString(")")
+	}
+
+	b.sql.WriteString(strings.Repeat(")", opened))
+
+	return nil
+}
+
+// resolve returns the scope the path of property starts from, along with the
+// rest of the path. Paths starting with a lambda variable start from the scope
+// of that lambda, others start from the model being queried.
+func (b *filterBuilder) resolve(property *PropertyNode) (*filterScope, []string) {
+	root := b.scope
+	for root.parent != nil {
+		root = root.parent
+	}
+
+	if len(property.Path) == 0 {
+		return root, nil
+	}
+
+	for s := b.scope; s != nil; s = s.parent {
+		if s.variable != "" && s.variable == property.Path[0] {
+			return s, property.Path[1:]
+		}
+	}
+
+	return root, property.Path
+}
+
+// openRelations writes an EXISTS subquery for every relation in path,
+// starting from owner, and returns the scope of the last relation along with
+// the number of subqueries to close. When lambda is "any" or "all" the last
+// relation must be a collection, otherwise none of them may be. The subquery
+// of the collection is negated for "all".
+func (b *filterBuilder) openRelations(owner *filterScope, path []string, property *PropertyNode, lambda string) (*filterScope, int, error) {
+	collection := lambda != ""
+	scope := owner
+
+	for i, name := range path {
+		last := i == len(path)-1
+		segment := strings.Join(path[:i+1], "/")
+
+		p, ok := scope.properties.lookup(name)
+		if !ok {
+			return nil, 0, unknownPropertyError("Filter", segment, property.Position)
+		}
+
+		if !p.allows(capFilter) {
+			return nil, 0, propertyNotAllowedError("Filter", segment, property.Position, capFilter)
+		}
+
+		rel := p.Relation
+		if rel == nil {
+			return nil, 0, invalidFilterError(property.Position, "The property '%s' at position %d is not a relation and cannot be navigated", segment, property.Position)
+		}
+
+		isCollection := rel.Type == schema.HasMany || rel.Type == schema.Many2Many
+		switch {
+		case collection && last && !isCollection:
+			return nil, 0, invalidFilterError(property.Position, "The property '%s' at position %d is not a collection and cannot be used with any() or all()", segment, property.Position)
+		case (!collection || !last) && isCollection:
+			return nil, 0, invalidFilterError(property.Position, "The property '%s' at position %d is a collection, use any() or all() to filter on it", segment, property.Position)
+		}
+
+		if i > 0 {
+			b.sql.WriteString(" AND ")
+		}
+
+		if last && lambda == "all" {
+			b.sql.WriteString("NOT ")
+		}
+
+		b.sql.WriteString("EXISTS (")
+		scope = b.openRelation(rel, scope)
+	}
+
+	if len(path) > 0 && !collection {
+		b.sql.WriteString(" AND ")
+	}
+
+	return scope, len(path), nil
+}
+
+// openRelation writes the start of a subquery selecting the rows related to
+// owner through rel, and makes the related model the current scope.
+func (b *filterBuilder) openRelation(rel *schema.Relationship, owner *filterScope) *filterScope {
+	parent := b.scope
+	child := &filterScope{
+		properties: propertiesOf(rel.FieldSchema, b.db.NamingStrategy),
+		table:      b.alias(rel.FieldSchema.Table),
+		qualify:    true,
+		parent:     parent,
+	}
+
+	table, ownerTable := b.quote(child.table), b.quote(owner.table)
+
+	from := b.quote(rel.FieldSchema.Table)
+	if child.table != rel.FieldSchema.Table {
+		from = fmt.Sprintf("%s %s", from, table)
+	}
+
+	var conditions []string
+	for _, ref := range rel.References {
+		switch {
+		case ref.PrimaryValue != "":
+			// polymorphic relations also match on the owner type
+			conditions = append(conditions, fmt.Sprintf("%s.%s = ?", table, b.quote(ref.ForeignKey.DBName)))
+			b.args = append(b.args, ref.PrimaryValue)
+		case rel.JoinTable != nil && ref.OwnPrimaryKey:
+			conditions = append(conditions, fmt.Sprintf("%s.%s = %s.%s", b.quote(rel.JoinTable.Table), b.quote(ref.ForeignKey.DBName), ownerTable, b.quote(ref.PrimaryKey.DBName)))
+		case rel.JoinTable != nil:
+			joinTable := b.quote(rel.JoinTable.Table)
+			from = fmt.Sprintf("%s JOIN %s ON %s.%s = %s.%s", from, joinTable, joinTable, b.quote(ref.ForeignKey.DBName), table, b.quote(ref.PrimaryKey.DBName))
+		case ref.OwnPrimaryKey:
+			conditions = append(conditions, fmt.Sprintf("%s.%s = %s.%s", table, b.quote(ref.ForeignKey.DBName), ownerTable, b.quote(ref.PrimaryKey.DBName)))
+		default:
+			conditions = append(conditions, fmt.Sprintf("%s.%s = %s.%s", table, b.quote(ref.PrimaryKey.DBName), ownerTable, b.quote(ref.ForeignKey.DBName)))
+		}
+	}
+
+	b.sql.WriteString(fmt.Sprintf("SELECT 1 FROM %s WHERE %s", from, strings.Join(conditions, " AND ")))
+
+	b.scope = child
+
+	return child
+}
+
+// alias returns the name to refer to table by in a new subquery, which has
+// to differ from the tables of the enclosing queries, e.g. for self
+// referencing relations.
+func (b *filterBuilder) alias(table string) string {
+	depth := 0
+	taken := false
+	for s := b.scope; s != nil; s = s.parent {
+		depth++
+		taken = taken || s.table == table
+	}
+
+	if !taken {
+		return table
+	}
+
+	return fmt.Sprintf("%s_%d", table, depth)
+}
+
+// operand is an operand of a comparison or a function compiled to SQL.
+type operand struct {
+	clause.Expr
+	// Type is the type of the value. Literals have none until they are
+	// converted to the type of what they are compared with.
+	Type    reflect.Type
+	Literal *LiteralNode
+	// Property is set when the operand is a property, whose case sensitivity
+	// applies to comparisons of it.
+	Property *property
+}
+
+// condition returns the SQL of a comparison or of a call of a function
+// returning a boolean, where scope is the scope of the properties reached
+// through relations.
+func (b *filterBuilder) condition(node Node, scope *filterScope) (clause.Expr, error) {
+	n, ok := node.(*ComparisonNode)
+	if !ok {
+		predicate, err := b.operand(node, scope)
+		if err != nil {
+			return clause.Expr{}, err
+		}
+
+		if predicate.Type != boolType {
+			return clause.Expr{}, invalidFilterError(node.Pos(), "The expression '%s' at position %d is not a condition and must be compared with a value", node, node.Pos())
+		}
+
+		return predicate.Expr, nil
+	}
+
+	left, err := b.operand(n.Left, scope)
+	if err != nil {
+		return clause.Expr{}, err
+	}
+
+	if left.Literal != nil {
+		return clause.Expr{}, invalidFilterError(n.Position, "The value %s at position %d must be compared with a property or function", n.Left, n.Position)
+	}
+
+	if n.Operator == "in" {
+		return b.inCondition(n, left)
+	}
+
+	right, err := b.operand(n.Right, scope)
+	if err != nil {
+		return clause.Expr{}, err
+	}
+
+	if right.Literal != nil && right.Literal.Kind == NullLiteral {
+		return b.nullCondition(n, left)
+	}
+
+	if right.Literal != nil {
+		if right, err = convertOperand(right, left.Type); err != nil {
+			return clause.Expr{}, err
+		}
+	} else if !compatibleTypes(left.Type, right.Type) {
+		return clause.Expr{}, invalidFilterError(n.Position, "The operands of '%s' at position %d cannot be compared", n, n.Position)
+	}
+
+	if right.Literal != nil && right.Literal.Kind == BooleanLiteral && (n.Operator == "eq" || n.Operator == "ne") {
+		return b.boolCondition(n, left), nil
+	}
+
+	operator := filterOperations[n.Operator]
+	fold := b.fold(left.Property, right.Property)
+
+	switch {
+	case n.Operator == "contains":
+		literal, ok := n.Right.(*LiteralNode)
+		if valueType(left.Type).Kind() != reflect.String || !ok {
+			return clause.Expr{}, invalidFilterError(n.Position, "The operator 'contains' at position %d can only be used on string properties with a string value", n.Position)
+		}
+
+		return b.dialect.Match(left.Expr, MatchContains, literal.Value, fold), nil
+	case n.Operator != "eq" && n.Operator != "ne" && !isOrdered(left.Type):
+		return clause.Expr{}, invalidFilterError(n.Position, "The operator '%s' at position %d cannot be used on '%s'", n.Operator, n.Position, n.Left)
+	case valueType(left.Type).Kind() != reflect.String || !fold:
+		return joinSQL(left.Expr, " "+operator+" ", right.Expr), nil
+	}
+
+	return b.dialect.CompareFold(left.Expr, operator, right.Expr), nil
+}
+
+// nullCondition returns the SQL of a comparison with null, which only eq and
+// ne support.
+func (b *filterBuilder) nullCondition(n *ComparisonNode, left operand) (clause.Expr, error) {
+	if _, ok := nullableType(left.Type); !ok {
+		return clause.Expr{}, invalidFilterError(n.Right.Pos(), "The property '%s' at position %d cannot be null and cannot be compared with null", n.Left, n.Position)
+	}
+
+	switch n.Operator {
+	case "eq":
+		return joinSQL(left.Expr, " IS NULL"), nil
+	case "ne":
+		return joinSQL(left.Expr, " IS NOT NULL"), nil
+	}
+
+	return clause.Expr{}, invalidFilterError(n.Position, "The operator '%s' at position %d cannot be used with null, expected 'eq' or 'ne'", n.Operator, n.Position)
+}
+
+// boolCondition returns the SQL of an eq or ne comparison with true or false,
+// writing the literal the way the dialect does. Not every database can compare
+// conditions as values, so calls of functions returning a boolean are negated
+// instead.
+func (b *filterBuilder) boolCondition(n *ComparisonNode, left operand) clause.Expr {
+	value := n.Right.(*LiteralNode).Value == "true"
+
+	if _, ok := n.Left.(*CallNode); ok {
+		if value == (n.Operator == "eq") {
+			return left.Expr
+		}
+
+		return joinSQL("NOT (", left.Expr, ")")
+	}
+
+	return joinSQL(left.Expr, " "+filterOperations[n.Operator]+" "+b.dialect.Bool(value))
+}
+
+// inCondition returns the SQL of an in operator, binding each value of its
+// list converted to the type of left.
+func (b *filterBuilder) inCondition(n *ComparisonNode, left operand) (clause.Expr, error) {
+	list, ok := n.Right.(*ListNode)
+	if !ok {
+		return clause.Expr{}, invalidFilterError(n.Right.Pos(), "The operator 'in' at position %d expects a list of values", n.Position)
+	}
+
+	limit := b.options.maxInListLength
+	if limit == 0 {
+		limit = defaultMaxInListLength
+	}
+
+	if len(list.Values) > limit {
+		return clause.Expr{}, invalidFilterError(list.Position, "The list at position %d has %d values, more than the maximum of %d", list.Position, len(list.Values), limit)
+	}
+
+	values := make([]clause.Expr, len(list.Values))
+	for i, literal := range list.Values {
+		if literal.Kind == NullLiteral {
+			return clause.Expr{}, invalidFilterError(literal.Position, "The list at position %d cannot contain null, compare with 'eq null' instead", list.Position)
+		}
+
+		value, err := convertLiteral(literal, left.Type)
+		if err != nil {
+			return clause.Expr{}, err
+		}
+
+		values[i] = bind(value)
+	}
+
+	if valueType(left.Type).Kind() == reflect.String && b.fold(left.Property) {
+		return b.dialect.InFold(left.Expr, values), nil
+	}
+
+	return joinSQL(left.Expr, " IN (", joinList(values, ", "), ")"), nil
+}
+
+// operand compiles a literal, property or function call, where scope is the
+// scope of the properties reached through relations.
+func (b *filterBuilder) operand(node Node, scope *filterScope) (operand, error) {
+	switch n := node.(type) {
+	case *LiteralNode:
+		return operand{Literal: n}, nil
+	case *PropertyNode:
+		return b.propertyOperand(n, scope)
+	case *CallNode:
+		return b.callOperand(n, scope)
+	case *ArithmeticNode:
+		return b.arithmeticOperand(n, scope)
+	}
+
+	return operand{}, invalidFilterError(node.Pos(), "The expression '%s' at position %d is not supported", node, node.Pos())
+}
+
+func (b *filterBuilder) propertyOperand(n *PropertyNode, navigated *filterScope) (operand, error) {
+	scope, path := b.resolve(n)
+	if len(path) > 0 {
+		scope = navigated
+	}
+
+	p, ok := scope.properties.lookup(n.Name)
+	if !ok {
+		return operand{}, unknownPropertyError("Filter", n.String(), n.Position)
+	}
+
+	if !p.allows(capFilter) {
+		return operand{}, propertyNotAllowedError("Filter", n.String(), n.Position, capFilter)
+	}
+
+	if p.Relation != nil {
+		return operand{}, invalidFilterError(n.Position, "The property '%s' at position %d is a relation and cannot be compared", n, n.Position)
+	}
+
+	return operand{Expr: clause.Expr{SQL: b.column(scope, p, scope != b.scope)}, Type: p.Field.Type, Property: p}, nil
+}
+
+func (b *filterBuilder) callOperand(n *CallNode, scope *filterScope) (operand, error) {
+	function := filterFunctions[n.Function]
+
+	args := make([]clause.Expr, len(n.Arguments))
+	var first operand
+	for i, argument := range n.Arguments {
+		arg, err := b.operand(argument, scope)
+		if err != nil {
+			return operand{}, err
+		}
+
+		param := function.Params[i]
+		if arg.Literal != nil {
+			if arg, err = convertOperand(arg, param); err != nil {
+				return operand{}, err
+			}
+		} else if !compatibleTypes(arg.Type, param) {
+			return operand{}, invalidFilterError(argument.Pos(), "The argument '%s' at position %d of the function '%s' is not %s", argument, argument.Pos(), n.Function, typeDescription(param))
+		}
+
+		if i == 0 {
+			first = arg
+		}
+
+		args[i] = arg.Expr
+	}
+
+	if kind, ok := matchFunctions[n.Function]; ok {
+		literal, ok := n.Arguments[1].(*LiteralNode)
+		if !ok {
+			return operand{}, invalidFilterError(n.Arguments[1].Pos(), "The second argument of the function '%s' at position %d must be a string value", n.Function, n.Position)
+		}
+
+		return operand{Expr: b.dialect.Match(args[0], kind, literal.Value, b.fold(first.Property)), Type: boolType}, nil
+	}
+
+	return operand{Expr: b.dialect.Function(n.Function, args), Type: function.Result}, nil
+}
+
+func (b *filterBuilder) arithmeticOperand(n *ArithmeticNode, scope *filterScope) (operand, error) {
+	var operands [2]operand
+	for i, node := range []Node{n.Left, n.Right} {
+		o, err := b.operand(node, scope)
+		if err != nil {
+			return operand{}, err
+		}
+
+		// literals are numbers of their own type, so that e.g. "age sub 30" can be negative
+		if o.Literal != nil {
+			if o, err = convertOperand(o, numberType(o.Literal)); err != nil {
+				return operand{}, err
+			}
+		}
+
+		if !isNumeric(valueType(o.Type)) {
+			return operand{}, invalidFilterError(node.Pos(), "The value '%s' at position %d of the operator '%s' is not a number", node, node.Pos(), n.Operator)
+		}
+
+		if arithmeticNeedsGrouping(node, n, i == 1) {
+			o.Expr = joinSQL("(", o.Expr, ")")
+		}
+
+		operands[i] = o
+	}
+
+	left, right := operands[0], operands[1]
+
+	result := int64Type
+	if isFloat(left.Type) || isFloat(right.Type) {
+		result = float64Type
+	}
+
+	if n.Operator == "mod" && result == float64Type {
+		return operand{}, invalidFilterError(n.Position, "The operator 'mod' at position %d can only be used on whole numbers", n.Position)
+	}
+
+	if (n.Operator == "div" || n.Operator == "mod") && right.Literal != nil && reflect.ValueOf(right.Vars[0]).IsZero() {
+		return operand{}, invalidFilterError(right.Literal.Position, "The value %s at position %d cannot be divided by", right.Literal, right.Literal.Position)
+	}
+
+	return operand{Expr: b.dialect.Arithmetic(left.Expr, n.Operator, right.Expr, result == int64Type), Type: result}, nil
+}
+
+// convertOperand converts the literal of o to a value of type t.
+func convertOperand(o operand, t reflect.Type) (operand, error) {
+	value, err := convertLiteral(o.Literal, t)
+	if err != nil {
+		return operand{}, err
+	}
+
+	o.Expr = bind(value)
+	o.Type = t
+
+	return o, nil
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// escapeLike escapes the LIKE wildcards in s so that it is matched literally.
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+var globEscaper = strings.NewReplacer("[", "[[]", "*", "[*]", "?", "[?]")
+
+// escapeGlob escapes the GLOB wildcards in s so that it is matched literally.
+func escapeGlob(s string) string {
+	return globEscaper.Replace(s)
+}
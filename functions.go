@@ -0,0 +1,98 @@
+package goatquery
+
+import (
+	"fmt"
+	"reflect"
+)
+
+var (
+	stringType = reflect.TypeOf("")
+	intType    = reflect.TypeOf(0)
+	boolType   = reflect.TypeOf(false)
+)
+
+// filterFunction is a function that can be called in a filter.
+type filterFunction struct {
+	// Params are the types of the parameters, of which the last Optional ones
+	// may be left out.
+	Params   []reflect.Type
+	Optional int
+	Result   reflect.Type
+}
+
+// filterFunctions are the functions of filters by name. The SQL of a call is
+// written by the dialect, see Dialect.Function.
+var filterFunctions = map[string]filterFunction{
+	"contains":   {Params: []reflect.Type{stringType, stringType}, Result: boolType},
+	"startswith": {Params: []reflect.Type{stringType, stringType}, Result: boolType},
+	"endswith":   {Params: []reflect.Type{stringType, stringType}, Result: boolType},
+	"tolower":    {Params: []reflect.Type{stringType}, Result: stringType},
+	"toupper":    {Params: []reflect.Type{stringType}, Result: stringType},
+	"trim":       {Params: []reflect.Type{stringType}, Result: stringType},
+	"length":     {Params: []reflect.Type{stringType}, Result: intType},
+	"indexof":    {Params: []reflect.Type{stringType, stringType}, Result: intType},
+	"concat":     {Params: []reflect.Type{stringType, stringType}, Result: stringType},
+	"substring":  {Params: []reflect.Type{stringType, intType, intType}, Optional: 1, Result: stringType},
+	"year":       {Params: []reflect.Type{timeType}, Result: intType},
+	"month":      {Params: []reflect.Type{timeType}, Result: intType},
+	"day":        {Params: []reflect.Type{timeType}, Result: intType},
+	"hour":       {Params: []reflect.Type{timeType}, Result: intType},
+	"minute":     {Params: []reflect.Type{timeType}, Result: intType},
+	"second":     {Params: []reflect.Type{timeType}, Result: intType},
+	"now":        {Result: timeType},
+}
+
+// dateParts are the functions returning a part of a date, which are the
+// fields of EXTRACT in upper case.
+var dateParts = map[string]bool{
+	"year":   true,
+	"month":  true,
+	"day":    true,
+	"hour":   true,
+	"minute": true,
+	"second": true,
+}
+
+// MatchKind is how the functions matching a string against a pattern, and
+// the contains operator, match, see Dialect.Match.
+type MatchKind int
+
+const (
+	MatchContains MatchKind = iota
+	MatchStartsWith
+	MatchEndsWith
+)
+
+// matchFunctions are the functions matching their first argument against
+// the string literal of their second.
+var matchFunctions = map[string]MatchKind{
+	"contains":   MatchContains,
+	"startswith": MatchStartsWith,
+	"endswith":   MatchEndsWith,
+}
+
+// Pattern returns value escaped by escape, with wildcard before and after it
+// as the kind of match requires.
+func (k MatchKind) Pattern(value string, wildcard string, escape func(string) string) string {
+	switch k {
+	case MatchStartsWith:
+		return escape(value) + wildcard
+	case MatchEndsWith:
+		return wildcard + escape(value)
+	}
+
+	return wildcard + escape(value) + wildcard
+}
+
+func (f filterFunction) arity() string {
+	required := len(f.Params) - f.Optional
+
+	switch {
+	case f.Optional > 0:
+		return fmt.Sprintf("%d to %d arguments", required, len(f.Params))
+	case required == 1:
+		return "1 argument"
+	}
+
+	return fmt.Sprintf("%d arguments", required)
+}
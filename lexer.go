@@ -0,0 +1,226 @@
+package goatquery
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+type tokenType int
+
+const (
+	tokenEOF tokenType = iota
+	tokenIdentifier
+	tokenString
+	tokenNumber
+	tokenDate
+	tokenDateTime
+	tokenGUID
+	tokenLParen
+	tokenRParen
+	tokenSlash
+	tokenColon
+	tokenComma
+)
+
+func (t tokenType) String() string {
+	switch t {
+	case tokenEOF:
+		return "end of input"
+	case tokenIdentifier:
+		return "identifier"
+	case tokenString:
+		return "string"
+	case tokenNumber:
+		return "number"
+	case tokenDate:
+		return "date"
+	case tokenDateTime:
+		return "date and time"
+	case tokenGUID:
+		return "guid"
+	case tokenLParen:
+		return "'('"
+	case tokenRParen:
+		return "')'"
+	case tokenSlash:
+		return "'/'"
+	case tokenColon:
+		return "':'"
+	case tokenComma:
+		return "','"
+	}
+
+	return "unknown token"
+}
+
+type token struct {
+	Type     tokenType
+	Literal  string
+	Position int
+}
+
+type lexer struct {
+	input    string
+	position int
+}
+
+func newLexer(input string) *lexer {
+	return &lexer{input: input}
+}
+
+// tokenize splits the input into tokens, always ending with a tokenEOF.
+func (l *lexer) tokenize() ([]token, error) {
+	var tokens []token
+
+	for {
+		tok, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+
+		tokens = append(tokens, tok)
+
+		if tok.Type == tokenEOF {
+			return tokens, nil
+		}
+	}
+}
+
+func (l *lexer) next() (token, error) {
+	l.skipWhitespace()
+
+	if l.position >= len(l.input) {
+		return token{Type: tokenEOF, Position: l.position}, nil
+	}
+
+	start := l.position
+	char := l.input[l.position]
+
+	switch {
+	case char == '(':
+		l.position++
+		return token{Type: tokenLParen, Literal: "(", Position: start}, nil
+	case char == ')':
+		l.position++
+		return token{Type: tokenRParen, Literal: ")", Position: start}, nil
+	case char == '/':
+		l.position++
+		return token{Type: tokenSlash, Literal: "/", Position: start}, nil
+	case char == ':':
+		l.position++
+		return token{Type: tokenColon, Literal: ":", Position: start}, nil
+	case char == ',':
+		l.position++
+		return token{Type: tokenComma, Literal: ",", Position: start}, nil
+	case char == '\'':
+		return l.readString()
+	case isDigit(char) || (char == '-' && l.position+1 < len(l.input) && isDigit(l.input[l.position+1])):
+		return l.readValue()
+	case isGUIDAt(l.input, l.position):
+		l.position += guidLength
+		return token{Type: tokenGUID, Literal: l.input[start:l.position], Position: start}, nil
+	case isIdentifierStart(char):
+		for l.position < len(l.input) && isIdentifierPart(l.input[l.position]) {
+			l.position++
+		}
+
+		return token{Type: tokenIdentifier, Literal: l.input[start:l.position], Position: start}, nil
+	}
+
+	return token{}, &SyntaxError{Position: start, Message: fmt.Sprintf("unexpected character '%c'", char)}
+}
+
+// readString reads a quoted string, in which a quote is escaped by doubling it.
+func (l *lexer) readString() (token, error) {
+	start := l.position
+	l.position++ // opening quote
+
+	var value strings.Builder
+	for {
+		end := strings.IndexByte(l.input[l.position:], '\'')
+		if end < 0 {
+			return token{}, &SyntaxError{Position: start, Message: "unterminated string literal"}
+		}
+
+		value.WriteString(l.input[l.position : l.position+end])
+		l.position += end + 1 // closing quote
+
+		if l.position >= len(l.input) || l.input[l.position] != '\'' {
+			return token{Type: tokenString, Literal: value.String(), Position: start}, nil
+		}
+
+		value.WriteByte('\'')
+		l.position++
+	}
+}
+
+var (
+	numberPattern   = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+	datePattern     = regexp.MustCompile(`^[0-9]{4}-[0-9]{2}-[0-9]{2}$`)
+	dateTimePattern = regexp.MustCompile(`^[0-9]{4}-[0-9]{2}-[0-9]{2}T[0-9]{2}:[0-9]{2}(:[0-9]{2}(\.[0-9]+)?)?(Z|[+-][0-9]{2}:[0-9]{2})?$`)
+	guidPattern     = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+)
+
+const guidLength = 36
+
+// readValue reads an unquoted literal starting with a digit or a minus: a
+// number, an ISO-8601 date or date and time, or a guid.
+func (l *lexer) readValue() (token, error) {
+	start := l.position
+	for l.position < len(l.input) && isValuePart(l.input[l.position]) {
+		l.position++
+	}
+
+	literal := l.input[start:l.position]
+
+	switch {
+	case numberPattern.MatchString(literal):
+		return token{Type: tokenNumber, Literal: literal, Position: start}, nil
+	case datePattern.MatchString(literal):
+		return token{Type: tokenDate, Literal: literal, Position: start}, nil
+	case dateTimePattern.MatchString(literal):
+		return token{Type: tokenDateTime, Literal: literal, Position: start}, nil
+	case guidPattern.MatchString(literal):
+		return token{Type: tokenGUID, Literal: literal, Position: start}, nil
+	}
+
+	return token{}, &SyntaxError{Position: start, Message: fmt.Sprintf("invalid literal '%s'", literal)}
+}
+
+// isGUIDAt reports whether a guid starts at position of input, which is
+// checked before identifiers as a guid may start with a letter.
+func isGUIDAt(input string, position int) bool {
+	end := position + guidLength
+	if end > len(input) || (end < len(input) && isIdentifierPart(input[end])) {
+		return false
+	}
+
+	return guidPattern.MatchString(input[position:end])
+}
+
+func (l *lexer) skipWhitespace() {
+	for l.position < len(l.input) && isWhitespace(l.input[l.position]) {
+		l.position++
+	}
+}
+
+func isWhitespace(char byte) bool {
+	return char == ' ' || char == '\t' || char == '\n' || char == '\r'
+}
+
+func isDigit(char byte) bool {
+	return char >= '0' && char <= '9'
+}
+
+func isValuePart(char byte) bool {
+	return isIdentifierPart(char) || char == '.' || char == ':' || char == '+' || char == '-'
+}
+
+func isIdentifierStart(char byte) bool {
+	return char == '_' || (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z')
+}
+
+func isIdentifierPart(char byte) bool {
+	return isIdentifierStart(char) || isDigit(char)
+}
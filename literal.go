@@ -0,0 +1,235 @@
+package goatquery
+
+import (
+	"database/sql/driver"
+	"encoding"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	int64Type           = reflect.TypeOf(int64(0))
+	float64Type         = reflect.TypeOf(float64(0))
+	uuidType            = reflect.TypeOf(uuid.UUID{})
+	valuerType          = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// dateLayouts are the accepted formats for date and time literals, tried in order.
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// nullableType returns the type of the values held by t when t can be null:
+// a pointer, or a struct holding a value and whether it is Valid, such as
+// sql.NullString or gorm.DeletedAt.
+func nullableType(t reflect.Type) (reflect.Type, bool) {
+	if t.Kind() == reflect.Ptr {
+		return t.Elem(), true
+	}
+
+	if t.Kind() == reflect.Struct && t.NumField() == 2 && t.Implements(valuerType) {
+		if valid := t.Field(1); valid.Name == "Valid" && valid.Type.Kind() == reflect.Bool {
+			return t.Field(0).Type, true
+		}
+	}
+
+	return t, false
+}
+
+// valueType returns the type of the values of a field of type t, looking
+// through the types that make it nullable.
+func valueType(t reflect.Type) reflect.Type {
+	for {
+		inner, ok := nullableType(t)
+		if !ok {
+			return t
+		}
+
+		t = inner
+	}
+}
+
+// convertLiteral converts a literal into a value of the Go type t, which is
+// the type of the field it is compared against. null converts to nil for the
+// types that can be null.
+func convertLiteral(n *LiteralNode, t reflect.Type) (interface{}, error) {
+	if n.Kind == NullLiteral {
+		if _, ok := nullableType(t); ok {
+			return nil, nil
+		}
+
+		return nil, literalTypeError(n, "a value, the property cannot be null")
+	}
+
+	t = valueType(t)
+
+	switch {
+	case t == timeType:
+		if n.Kind == StringLiteral || n.Kind == DateLiteral || n.Kind == DateTimeLiteral {
+			for _, layout := range dateLayouts {
+				if value, err := time.Parse(layout, n.Value); err == nil {
+					return value, nil
+				}
+			}
+		}
+
+		return nil, literalTypeError(n, "a date such as 2024-01-31 or 2024-01-31T10:00:00Z")
+	case t == uuidType:
+		if n.Kind == StringLiteral || n.Kind == GUIDLiteral {
+			if value, err := uuid.Parse(n.Value); err == nil {
+				return value, nil
+			}
+		}
+
+		return nil, literalTypeError(n, "a guid")
+	case n.Kind == StringLiteral && t.Kind() != reflect.String && reflect.PtrTo(t).Implements(textUnmarshalerType):
+		// e.g. enums parsed from their names
+		value := reflect.New(t)
+		if err := value.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(n.Value)); err == nil {
+			return value.Elem().Interface(), nil
+		}
+
+		return nil, literalTypeError(n, "a valid "+t.Name())
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		if n.Kind == StringLiteral {
+			return n.Value, nil
+		}
+
+		return nil, literalTypeError(n, "a quoted string")
+	case reflect.Bool:
+		if n.Kind == BooleanLiteral {
+			return n.Value == "true", nil
+		}
+
+		return nil, literalTypeError(n, "true or false")
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n.Kind == NumberLiteral {
+			if value, err := strconv.ParseInt(n.Value, 10, t.Bits()); err == nil {
+				return value, nil
+			}
+		}
+
+		return nil, literalTypeError(n, "an integer")
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if n.Kind == NumberLiteral {
+			if value, err := strconv.ParseUint(n.Value, 10, t.Bits()); err == nil {
+				return value, nil
+			}
+		}
+
+		return nil, literalTypeError(n, "a positive integer")
+	case reflect.Float32, reflect.Float64:
+		if n.Kind == NumberLiteral {
+			if value, err := strconv.ParseFloat(n.Value, t.Bits()); err == nil {
+				return value, nil
+			}
+		}
+
+		return nil, literalTypeError(n, "a number")
+	}
+
+	return literalValue(n), nil
+}
+
+// literalValue converts a literal into a Go value based on its kind alone.
+func literalValue(n *LiteralNode) interface{} {
+	switch n.Kind {
+	case BooleanLiteral:
+		return n.Value == "true"
+	case NumberLiteral:
+		if i, err := strconv.ParseInt(n.Value, 10, 64); err == nil {
+			return i
+		}
+
+		if f, err := strconv.ParseFloat(n.Value, 64); err == nil {
+			return f
+		}
+	}
+
+	return n.Value
+}
+
+func literalTypeError(n *LiteralNode, expected string) error {
+	return invalidFilterError(n.Position, "The value %s at position %d is not valid, expected %s", n, n.Position, expected)
+}
+
+// compatibleTypes reports whether values of the types a and b can be compared.
+func compatibleTypes(a reflect.Type, b reflect.Type) bool {
+	a, b = valueType(a), valueType(b)
+	if a == b {
+		return true
+	}
+
+	switch {
+	case a.Kind() == reflect.String && b.Kind() == reflect.String:
+		return true
+	case isNumeric(a) && isNumeric(b):
+		return true
+	}
+
+	return false
+}
+
+func isNumeric(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+
+	return false
+}
+
+func isFloat(t reflect.Type) bool {
+	kind := valueType(t).Kind()
+	return kind == reflect.Float32 || kind == reflect.Float64
+}
+
+// numberType returns the type of the number n holds, a float64 when it has
+// a fraction and an int64 otherwise.
+func numberType(n *LiteralNode) reflect.Type {
+	if n.Kind == NumberLiteral && strings.Contains(n.Value, ".") {
+		return float64Type
+	}
+
+	return int64Type
+}
+
+// typeDescription describes the values of type t in errors.
+func typeDescription(t reflect.Type) string {
+	t = valueType(t)
+
+	switch {
+	case t == timeType:
+		return "a date"
+	case t.Kind() == reflect.String:
+		return "a string"
+	case t.Kind() == reflect.Bool:
+		return "a boolean"
+	case isNumeric(t):
+		return "a number"
+	}
+
+	return "a " + t.Name()
+}
+
+// isOrdered reports whether values of type t can be compared with gt, ge, lt and le.
+func isOrdered(t reflect.Type) bool {
+	t = valueType(t)
+
+	return t == timeType || t.Kind() == reflect.String || isNumeric(t)
+}
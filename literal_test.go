@@ -0,0 +1,86 @@
+package goatquery
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type testStatus int
+
+const (
+	testStatusActive testStatus = iota + 1
+	testStatusPending
+)
+
+func (s *testStatus) UnmarshalText(text []byte) error {
+	switch strings.ToLower(string(text)) {
+	case "active":
+		*s = testStatusActive
+	case "pending":
+		*s = testStatusPending
+	default:
+		return errors.New("unknown status")
+	}
+
+	return nil
+}
+
+func Test_ConvertLiteral(t *testing.T) {
+	date := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		literal  LiteralNode
+		value    interface{}
+		expected interface{}
+	}{
+		{LiteralNode{Kind: StringLiteral, Value: "goat"}, new(string), "goat"},
+		{LiteralNode{Kind: NumberLiteral, Value: "21"}, new(*int), int64(21)},
+		{LiteralNode{Kind: NullLiteral, Value: "null"}, new(*int), nil},
+		{LiteralNode{Kind: StringLiteral, Value: "goat"}, new(sql.NullString), "goat"},
+		{LiteralNode{Kind: NullLiteral, Value: "null"}, new(sql.NullString), nil},
+		{LiteralNode{Kind: NumberLiteral, Value: "1.5"}, new(sql.NullFloat64), 1.5},
+		{LiteralNode{Kind: BooleanLiteral, Value: "true"}, new(sql.NullBool), true},
+		{LiteralNode{Kind: DateLiteral, Value: "2024-01-31"}, new(sql.NullTime), date},
+		{LiteralNode{Kind: DateLiteral, Value: "2024-01-31"}, new(gorm.DeletedAt), date},
+		{LiteralNode{Kind: NullLiteral, Value: "null"}, new(gorm.DeletedAt), nil},
+		{LiteralNode{Kind: DateTimeLiteral, Value: "2024-01-31T00:00:00Z"}, new(*time.Time), date},
+		{LiteralNode{Kind: StringLiteral, Value: "Pending"}, new(testStatus), testStatusPending},
+		{LiteralNode{Kind: NumberLiteral, Value: "2"}, new(testStatus), int64(2)},
+	}
+
+	for _, test := range tests {
+		value, err := convertLiteral(&test.literal, reflect.TypeOf(test.value).Elem())
+
+		if assert.NoError(t, err, test.literal.String()) {
+			assert.Equal(t, test.expected, value, test.literal.String())
+		}
+	}
+}
+
+func Test_ConvertLiteralTypeMismatch(t *testing.T) {
+	tests := []struct {
+		literal LiteralNode
+		value   interface{}
+		message string
+	}{
+		{LiteralNode{Position: 7, Kind: NullLiteral, Value: "null"}, new(int), "The value null at position 7 is not valid, expected a value, the property cannot be null"},
+		{LiteralNode{Position: 7, Kind: NumberLiteral, Value: "21"}, new(sql.NullString), "The value 21 at position 7 is not valid, expected a quoted string"},
+		{LiteralNode{Position: 7, Kind: StringLiteral, Value: "yes"}, new(*bool), "The value 'yes' at position 7 is not valid, expected true or false"},
+		{LiteralNode{Position: 7, Kind: StringLiteral, Value: "unknown"}, new(testStatus), "The value 'unknown' at position 7 is not valid, expected a valid testStatus"},
+		{LiteralNode{Position: 7, Kind: GUIDLiteral, Value: "1b4e28ba-2fa1-11d2-883f-0016d3cca427"}, new(sql.NullTime), "The value 1b4e28ba-2fa1-11d2-883f-0016d3cca427 at position 7 is not valid, expected a date such as 2024-01-31 or 2024-01-31T10:00:00Z"},
+	}
+
+	for _, test := range tests {
+		_, err := convertLiteral(&test.literal, reflect.TypeOf(test.value).Elem())
+
+		assert.ErrorIs(t, err, ErrInvalidFilter)
+		assert.EqualError(t, err, test.message)
+	}
+}
@@ -0,0 +1,251 @@
+package goatquery
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// SearchFunc applies the Search query parameter to db.
+type SearchFunc func(db *gorm.DB, searchTerm string) *gorm.DB
+
+// Option configures how ApplyWith applies a query.
+type Option func(*options)
+
+type options struct {
+	maxTop            *int
+	search            SearchFunc
+	defaultOrder      string
+	allowedProperties map[string]bool
+	countFunc         *CountFunc
+	filter            filterOptions
+}
+
+// filterOptions are the options affecting filters, including the filters of
+// expanded relations.
+type filterOptions struct {
+	// dialect writes the SQL, or is nil for the dialect of the database.
+	dialect       Dialect
+	caseSensitive bool
+	// maxInListLength is the maximum number of values of an in operator, or 0
+	// for defaultMaxInListLength.
+	maxInListLength int
+}
+
+// dialectFor returns the dialect to write the filters of queries through db in.
+func (o filterOptions) dialectFor(db *gorm.DB) Dialect {
+	if o.dialect != nil {
+		return o.dialect
+	}
+
+	return DialectOf(db)
+}
+
+// defaultMaxInListLength is the maximum number of values of an in operator
+// without WithMaxInListLength.
+const defaultMaxInListLength = 100
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// defaults fills in the parameters query leaves to the options.
+func (o options) defaults(query Query) Query {
+	if o.maxTop != nil && query.Top == 0 {
+		// If no top query was provided, set to max top.
+		query.Top = *o.maxTop
+	}
+
+	if query.OrderBy == "" {
+		query.OrderBy = o.defaultOrder
+	}
+
+	return query
+}
+
+func legacyOptions(maxTop *int, searchFunc func(db *gorm.DB, searchTerm string) *gorm.DB) []Option {
+	opts := []Option{WithSearch(searchFunc)}
+	if maxTop != nil {
+		opts = append(opts, WithMaxTop(*maxTop))
+	}
+
+	return opts
+}
+
+// WithMaxTop rejects queries with a Top greater than maxTop, and uses maxTop
+// when the query has no Top.
+func WithMaxTop(maxTop int) Option {
+	return func(o *options) {
+		o.maxTop = &maxTop
+	}
+}
+
+// WithSearch applies the Search query parameter with search. Without it
+// Search is ignored.
+func WithSearch(search SearchFunc) Option {
+	return func(o *options) {
+		o.search = search
+	}
+}
+
+// WithDefaultOrder uses orderBy, in the form of the OrderBy query parameter,
+// when the query has no OrderBy. BuildPagedResponse doesn't know about it, so
+// when paging with a Cursor pass it the query returned by ApplyWith.
+func WithDefaultOrder(orderBy string) Option {
+	return func(o *options) {
+		o.defaultOrder = orderBy
+	}
+}
+
+// WithAllowedProperties only lets the query parameters Filter, OrderBy,
+// Select and Expand use the given properties of the model, by their json
+// names. Any other property is reported as not existing. Relations of allowed
+// properties can still be navigated, e.g. "address/postcode" is allowed with
+// "address".
+func WithAllowedProperties(properties ...string) Option {
+	return func(o *options) {
+		if o.allowedProperties == nil {
+			o.allowedProperties = map[string]bool{}
+		}
+
+		for _, property := range properties {
+			o.allowedProperties[property] = true
+		}
+	}
+}
+
+// WithDeferredCount leaves the count to *countFunc instead of running it, so
+// ApplyWith returns no count. countFunc is called with the number of rows
+// once the page is loaded. When the page is shorter than Top the count
+// follows from it, so the count query is only run when there may be rows
+// after the page.
+func WithDeferredCount(countFunc *CountFunc) Option {
+	return func(o *options) {
+		o.countFunc = countFunc
+	}
+}
+
+// WithCaseSensitive sets whether filters compare strings case sensitively,
+// which they don't by default. Properties can override it with the goatquery
+// struct tag, `goatquery:"casesensitive"` or `goatquery:"caseinsensitive"`.
+func WithCaseSensitive(caseSensitive bool) Option {
+	return func(o *options) {
+		o.filter.caseSensitive = caseSensitive
+	}
+}
+
+// WithDialect writes filters with dialect instead of the dialect DialectOf
+// returns for the database, e.g. for a database it doesn't know, with a
+// dialect embedding StandardDialect.
+func WithDialect(dialect Dialect) Option {
+	return func(o *options) {
+		o.filter.dialect = dialect
+	}
+}
+
+// WithMaxInListLength rejects filters with an in operator comparing with more
+// than maxLength values, e.g. "status in ('active', 'pending')" has 2. The
+// maximum is 100 by default.
+func WithMaxInListLength(maxLength int) Option {
+	return func(o *options) {
+		o.filter.maxInListLength = maxLength
+	}
+}
+
+// checkAllowedProperties reports the first property used by query that isn't
+// in allowed, unless allowed is nil. Malformed parameters are left to be
+// reported when they are applied.
+func checkAllowedProperties(query Query, allowed map[string]bool) error {
+	if allowed == nil {
+		return nil
+	}
+
+	if query.Filter != "" {
+		if node, err := ParseFilter(query.Filter); err == nil {
+			var denied *PropertyNode
+			walkModelProperties(node, nil, func(property *PropertyNode) {
+				if denied == nil && !allowed[rootProperty(property)] {
+					denied = property
+				}
+			})
+
+			if denied != nil {
+				return unknownPropertyError("Filter", rootProperty(denied), denied.Position)
+			}
+		}
+	}
+
+	if query.OrderBy != "" {
+		for _, item := range splitList(query.OrderBy) {
+			parts := strings.Fields(item.Value)
+			if len(parts) > 0 && !allowed[parts[0]] {
+				return unknownPropertyError("OrderBy", parts[0], item.Position)
+			}
+		}
+	}
+
+	if query.Select != "" {
+		for _, item := range splitList(query.Select) {
+			if item.Value != "" && !allowed[item.Value] {
+				return unknownPropertyError("Select", item.Value, item.Position)
+			}
+		}
+	}
+
+	if query.Expand != "" {
+		items, _ := parseExpand(query.Expand, 0)
+		for _, item := range items {
+			if !allowed[item.Property] {
+				return unknownPropertyError("Expand", item.Property, item.Position)
+			}
+		}
+	}
+
+	return nil
+}
+
+// walkModelProperties calls fn with every property of node that belongs to
+// the model, skipping the ones that refer to the rows of a lambda through one
+// of variables.
+func walkModelProperties(node Node, variables []string, fn func(*PropertyNode)) {
+	switch n := node.(type) {
+	case *LogicalNode:
+		walkModelProperties(n.Left, variables, fn)
+		walkModelProperties(n.Right, variables, fn)
+	case *NotNode:
+		walkModelProperties(n.Operand, variables, fn)
+	case *ComparisonNode, *CallNode:
+		walkOperands(n, func(property *PropertyNode) {
+			visitModelProperty(property, variables, fn)
+		})
+	case *LambdaNode:
+		visitModelProperty(n.Collection, variables, fn)
+		if n.Predicate != nil {
+			walkModelProperties(n.Predicate, append(variables[:len(variables):len(variables)], n.Variable), fn)
+		}
+	}
+}
+
+func visitModelProperty(property *PropertyNode, variables []string, fn func(*PropertyNode)) {
+	for _, variable := range variables {
+		if len(property.Path) > 0 && property.Path[0] == variable {
+			return
+		}
+	}
+
+	fn(property)
+}
+
+// rootProperty returns the property of the model property starts from.
+func rootProperty(property *PropertyNode) string {
+	if len(property.Path) > 0 {
+		return property.Path[0]
+	}
+
+	return property.Name
+}
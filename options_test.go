@@ -0,0 +1,210 @@
+package goatquery
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func Test_ApplyWithoutOptions(t *testing.T) {
+	query := Query{Top: 3, Search: "goat"}
+
+	sql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		res, _, _, _ := ApplyWith(tx.Model(&User{}), query, &[]User{})
+		return res.Find(&[]User{})
+	})
+
+	expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		return tx.Model(&User{}).Limit(query.Top).Find(&[]User{})
+	})
+
+	assert.Equal(t, expectedSql, sql)
+}
+
+func Test_ApplyWithMaxTop(t *testing.T) {
+	sql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		res, _, _, _ := ApplyWith(tx.Model(&User{}), Query{}, &[]User{}, WithMaxTop(5))
+		return res.Find(&[]User{})
+	})
+
+	expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		return tx.Model(&User{}).Limit(5).Find(&[]User{})
+	})
+
+	assert.Equal(t, expectedSql, sql)
+
+	_, _, _, err := ApplyWith(DB.Model(&User{}), Query{Top: 6}, &[]User{}, WithMaxTop(5))
+
+	assert.ErrorIs(t, err, ErrTopExceedsMax)
+}
+
+func Test_ApplyWithSearch(t *testing.T) {
+	search := func(db *gorm.DB, searchTerm string) *gorm.DB {
+		return db.Where("firstname = ?", searchTerm)
+	}
+
+	sql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		res, _, _, _ := ApplyWith(tx.Model(&User{}), Query{Search: "goat"}, &[]User{}, WithSearch(search))
+		return res.Find(&[]User{})
+	})
+
+	expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		return tx.Model(&User{}).Where("firstname = ?", "goat").Find(&[]User{})
+	})
+
+	assert.Equal(t, expectedSql, sql)
+}
+
+func Test_ApplyWithDefaultOrder(t *testing.T) {
+	tests := []struct {
+		query  Query
+		column string
+	}{
+		{Query{}, "lastname"},
+		{Query{OrderBy: "firstname"}, "firstname"},
+	}
+
+	for _, test := range tests {
+		sql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			res, _, _, _ := ApplyWith(tx.Model(&User{}), test.query, &[]User{}, WithDefaultOrder("lastname"))
+			return res.Find(&[]User{})
+		})
+
+		expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			return tx.Model(&User{}).Order(clause.OrderByColumn{Column: clause.Column{Name: test.column}}).Find(&[]User{})
+		})
+
+		assert.Equal(t, expectedSql, sql)
+	}
+}
+
+func Test_ApplyWithAllowedProperties(t *testing.T) {
+	allowed := WithAllowedProperties("firstname", "address", "permissions")
+
+	valid := []Query{
+		{Filter: "firstname eq 'goat' and address/postcode eq 'AB1'"},
+		{Filter: "permissions/any(p: p/name eq 'admin' and firstname eq 'goat')"},
+		{OrderBy: "firstname desc", Select: "firstname", Expand: "address($select=postcode)"},
+	}
+
+	for _, query := range valid {
+		_, _, _, err := ApplyWith(DB.Model(&User{}), query, &[]User{}, allowed)
+		assert.NoError(t, err, query)
+	}
+
+	tests := []struct {
+		query    Query
+		clause   string
+		property string
+		position int
+	}{
+		{Query{Filter: "firstname eq 'goat' or lastname eq 'goat'"}, "Filter", "lastname", 23},
+		{Query{Filter: "permissions/any(p: p/name eq 'admin' and age gt 21)"}, "Filter", "age", 41},
+		{Query{Filter: "tags/any()"}, "Filter", "tags", 0},
+		{Query{Filter: "startswith(firstname, 'a') or length(lastname) gt 1"}, "Filter", "lastname", 37},
+		{Query{OrderBy: "firstname, age desc"}, "OrderBy", "age", 11},
+		{Query{Select: "firstname,email"}, "Select", "email", 10},
+		{Query{Expand: "address,tags"}, "Expand", "tags", 8},
+	}
+
+	for _, test := range tests {
+		_, _, _, err := ApplyWith(DB.Model(&User{}), test.query, &[]User{}, allowed)
+
+		var queryErr *QueryError
+		if assert.ErrorAs(t, err, &queryErr, test.query) {
+			assert.ErrorIs(t, err, ErrUnknownProperty)
+			assert.Equal(t, test.clause, queryErr.Clause)
+			assert.Equal(t, test.property, queryErr.Property)
+			assert.Equal(t, test.position, queryErr.Position)
+		}
+	}
+}
+
+func Test_ApplyWithCaseSensitive(t *testing.T) {
+	tests := []struct {
+		query    Query
+		expected string
+		args     []interface{}
+	}{
+		{Query{Filter: "firstname eq 'Goat' and age eq 2"}, "firstname = ? and age = ?", []interface{}{"Goat", 2}},
+		{Query{Filter: "firstname contains 'Goat'"}, "firstname GLOB ?", []interface{}{"*Goat*"}},
+		{Query{Filter: "address/postcode eq 'AB1'"}, "EXISTS (SELECT 1 FROM addresses WHERE addresses.id = users.address_id AND addresses.postcode = ?)", []interface{}{"AB1"}},
+	}
+
+	for _, test := range tests {
+		sql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			res, _, _, _ := ApplyWith(tx.Model(&User{}), test.query, &[]User{}, WithCaseSensitive(true))
+			return res.Find(&[]User{})
+		})
+
+		expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			return tx.Model(&User{}).Where(test.expected, test.args...).Find(&[]User{})
+		})
+
+		assert.Equal(t, expectedSql, sql, test.query.Filter)
+	}
+}
+
+func Test_ApplyWithDialect(t *testing.T) {
+	sql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		res, _, _, _ := ApplyWith(tx.Model(&User{}), Query{Filter: "firstname eq 'goat' and contributor eq false"}, &[]User{}, WithDialect(postgresDialect{}))
+		return res.Find(&[]User{})
+	})
+
+	expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		return tx.Model(&User{}).Where("LOWER(firstname) = LOWER(?) and contributor = FALSE", "goat").Find(&[]User{})
+	})
+
+	assert.Equal(t, expectedSql, sql)
+}
+
+// upperDialect folds case with UPPER rather than LOWER.
+type upperDialect struct {
+	StandardDialect
+}
+
+func (upperDialect) CompareFold(left clause.Expr, operator string, right clause.Expr) clause.Expr {
+	return joinSQL("UPPER(", left, ") "+operator+" UPPER(", right, ")")
+}
+
+func Test_ApplyWithEmbeddedStandardDialect(t *testing.T) {
+	sql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		res, _, _, _ := ApplyWith(tx.Model(&User{}), Query{Filter: "firstname eq 'goat' and contributor eq false"}, &[]User{}, WithDialect(upperDialect{}))
+		return res.Find(&[]User{})
+	})
+
+	expectedSql := DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		return tx.Model(&User{}).Where("UPPER(firstname) = UPPER(?) and contributor = FALSE", "goat").Find(&[]User{})
+	})
+
+	assert.Equal(t, expectedSql, sql)
+}
+
+func Test_ApplyWithMaxInListLength(t *testing.T) {
+	_, _, _, err := ApplyWith(DB.Model(&User{}), Query{Filter: "age in (1, 2)"}, &[]User{}, WithMaxInListLength(2))
+	assert.NoError(t, err)
+
+	_, _, _, err = ApplyWith(DB.Model(&User{}), Query{Filter: "age in (1, 2, 3)"}, &[]User{}, WithMaxInListLength(2))
+
+	var queryErr *QueryError
+	if assert.ErrorAs(t, err, &queryErr) {
+		assert.ErrorIs(t, err, ErrInvalidFilter)
+		assert.Equal(t, 7, queryErr.Position)
+	}
+}
+
+func Test_ApplyWithDeferredCount(t *testing.T) {
+	var countFunc CountFunc
+
+	_, _, count, err := ApplyWith(DB.Model(&User{}), Query{Count: true, Top: 5}, &[]User{}, WithDeferredCount(&countFunc))
+	assert.NoError(t, err)
+	assert.Nil(t, count)
+
+	if assert.NotNil(t, countFunc) {
+		count, err = countFunc(2)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), *count)
+	}
+}
@@ -0,0 +1,76 @@
+package goatquery
+
+import (
+	"strings"
+
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// orderByTerm is one "property [asc|desc]" item of the OrderBy query parameter.
+type orderByTerm struct {
+	Property string
+	Column   string
+	Desc     bool
+	// Field is the gorm field of Column, or nil when there is none.
+	Field *schema.Field
+	// Selectable is set when clients may see the values of Property, which
+	// cursors are made from.
+	Selectable bool
+}
+
+func (t orderByTerm) clause() clause.OrderByColumn {
+	return clause.OrderByColumn{Column: clause.Column{Name: t.Column}, Desc: t.Desc}
+}
+
+// parseOrderBy parses a comma separated list of "property [asc|desc]" terms,
+// resolving every property to its column.
+func parseOrderBy(input string, properties *modelProperties) ([]orderByTerm, error) {
+	var terms []orderByTerm
+
+	for _, item := range splitList(input) {
+		parts := strings.Fields(item.Value)
+		if len(parts) == 0 || len(parts) > 2 {
+			return nil, newQueryError(ErrInvalidOrderBy, "OrderBy", item.Position, "The query parameter 'OrderBy' is not valid, expected 'property [asc|desc]' at position %d", item.Position)
+		}
+
+		property := parts[0]
+		p, ok := properties.lookup(property)
+		if !ok {
+			return nil, unknownPropertyError("OrderBy", property, item.Position)
+		}
+
+		if !p.allows(capSort) {
+			return nil, propertyNotAllowedError("OrderBy", property, item.Position, capSort)
+		}
+
+		if p.Relation != nil {
+			err := newQueryError(ErrInvalidOrderBy, "OrderBy", item.Position, "The property '%s' supplied for the query parameter 'OrderBy' is a relation and cannot be sorted by", property)
+			err.Property = property
+
+			return nil, err
+		}
+
+		term := orderByTerm{
+			Property:   property,
+			Column:     p.Column,
+			Field:      p.SchemaField,
+			Selectable: p.allows(capSelect),
+		}
+
+		if len(parts) == 2 {
+			switch strings.ToLower(parts[1]) {
+			case "asc":
+			case "desc":
+				term.Desc = true
+			default:
+				position := item.Position + strings.LastIndex(item.Value, parts[1])
+				return nil, newQueryError(ErrInvalidOrderBy, "OrderBy", position, "The direction '%s' supplied for the query parameter 'OrderBy' is not valid, expected 'asc' or 'desc'", parts[1])
+			}
+		}
+
+		terms = append(terms, term)
+	}
+
+	return terms, nil
+}
@@ -0,0 +1,507 @@
+package goatquery
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SyntaxError is returned when a filter cannot be parsed.
+type SyntaxError struct {
+	Position int
+	Message  string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at position %d: %s", e.Position, e.Message)
+}
+
+type parser struct {
+	tokens   []token
+	position int
+}
+
+// ParseFilter parses a filter expression such as
+// "firstname eq 'goat' and age eq 21" into its syntax tree.
+//
+// The grammar is:
+//
+//	filter     = or
+//	or         = and { "or" and }
+//	and        = not { "and" not }
+//	not        = "not" not | comparison
+//	comparison = additive [ operator additive | "in" list ]
+//	additive   = term { ( "add" | "sub" ) term }
+//	term       = primary { ( "mul" | "div" | "mod" ) primary }
+//	primary    = "(" or ")" | lambda | call | property | literal
+//	lambda     = property "/" ("any" | "all") "(" [ identifier ":" or ] ")"
+//	call       = function "(" [ additive { "," additive } ] ")"
+//	list       = "(" literal { "," literal } ")"
+//	property   = identifier { "/" identifier }
+//	literal    = string | number | date | datetime | guid | "true" | "false" | "null"
+//
+// so "mul", "div" and "mod" bind tighter than "add" and "sub", which bind
+// tighter than the comparison operators, then "not", "and" and "or", and
+// operators of equal precedence associate to the left. Parentheses override
+// the precedence, e.g. "(a eq 1 or b eq 2) and c eq 3" or
+// "(price add tax) mul quantity gt 100".
+//
+// The operands of "and", "or" and "not" are conditions: comparisons, lambdas
+// and calls of functions returning a boolean. The operands of comparisons,
+// arithmetic operators and functions are values, and the left operand of a
+// comparison can't be a literal.
+//
+// Strings are single quoted, with quotes inside them written twice:
+//
+//	lastname eq 'O''Brien'
+//
+// Dates, such as 2024-01-31 or 2024-01-31T10:00:00Z, and guids are written
+// without quotes.
+//
+// The functions are startswith, endswith, contains, tolower, toupper, trim,
+// length, indexof, concat and substring for strings, year, month, day, hour,
+// minute and second for dates, and now, the time of the database, e.g.
+// "length(firstname) gt 3", "year(createdAt) eq 2024" or "createdAt lt now()". Calls to functions returning a boolean can be
+// used as conditions on their own, e.g. "startswith(firstname, 'go')".
+//
+// The arithmetic operators add, sub, mul, div and mod take numbers, e.g.
+// "age mod 2 eq 0" or "price mul quantity gt 100". div divides whole numbers
+// without a remainder on every database, e.g. "age div 2 eq 1" for 2 and 3.
+//
+// The in operator compares with a list of values, e.g.
+// "status in ('active', 'pending')".
+//
+// Inside a lambda, properties prefixed with its variable refer to the rows of
+// the collection, e.g. "permissions/any(p: p/name eq 'admin')". The predicate
+// may only be omitted for "any".
+func ParseFilter(input string) (Node, error) {
+	tokens, err := newLexer(input).tokenize()
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if node, err = p.condition(node); err != nil {
+		return nil, err
+	}
+
+	if tok := p.peek(); tok.Type != tokenEOF {
+		return nil, p.unexpected(tok, "'and' or 'or'")
+	}
+
+	return node, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.position]
+}
+
+func (p *parser) advance() token {
+	tok := p.tokens[p.position]
+	if tok.Type != tokenEOF {
+		p.position++
+	}
+
+	return tok
+}
+
+func (p *parser) unexpected(tok token, expected string) error {
+	if tok.Type == tokenEOF {
+		return &SyntaxError{Position: tok.Position, Message: fmt.Sprintf("expected %s but reached end of input", expected)}
+	}
+
+	return &SyntaxError{Position: tok.Position, Message: fmt.Sprintf("expected %s but found '%s'", expected, tok.Literal)}
+}
+
+func (p *parser) isKeyword(tok token, keywords ...string) bool {
+	if tok.Type != tokenIdentifier {
+		return false
+	}
+
+	for _, keyword := range keywords {
+		if strings.EqualFold(tok.Literal, keyword) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// condition checks that node, which was parsed up to the current token, is a
+// condition rather than a value.
+func (p *parser) condition(node Node) (Node, error) {
+	switch n := node.(type) {
+	case *ComparisonNode, *LogicalNode, *NotNode, *LambdaNode:
+		return node, nil
+	case *CallNode:
+		if filterFunctions[n.Function].Result == boolType {
+			return node, nil
+		}
+	}
+
+	return nil, p.unexpected(p.peek(), "comparison operator")
+}
+
+// value checks that node is a value rather than a condition.
+func (p *parser) value(node Node) (Node, error) {
+	switch node.(type) {
+	case *PropertyNode, *LiteralNode, *CallNode, *ArithmeticNode:
+		return node, nil
+	}
+
+	return nil, &SyntaxError{Position: node.Pos(), Message: fmt.Sprintf("expected a value but found the condition '%s'", node)}
+}
+
+func (p *parser) parseOr() (Node, error) {
+	return p.parseLogical("or", p.parseAnd)
+}
+
+func (p *parser) parseAnd() (Node, error) {
+	return p.parseLogical("and", p.parseNot)
+}
+
+// parseLogical parses a left associative chain of conditions joined by operator.
+func (p *parser) parseLogical(operator string, parseOperand func() (Node, error)) (Node, error) {
+	left, err := parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isKeyword(p.peek(), operator) {
+		if left, err = p.condition(left); err != nil {
+			return nil, err
+		}
+
+		op := p.advance()
+
+		right, err := parseOperand()
+		if err != nil {
+			return nil, err
+		}
+
+		if right, err = p.condition(right); err != nil {
+			return nil, err
+		}
+
+		left = &LogicalNode{Position: op.Position, Left: left, Operator: operator, Right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseNot() (Node, error) {
+	if !p.isKeyword(p.peek(), "not") {
+		return p.parseComparison()
+	}
+
+	op := p.advance()
+
+	operand, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	if operand, err = p.condition(operand); err != nil {
+		return nil, err
+	}
+
+	return &NotNode{Position: op.Position, Operand: operand}, nil
+}
+
+func (p *parser) isOperator(tok token) bool {
+	_, ok := filterOperations[strings.ToLower(tok.Literal)]
+	return tok.Type == tokenIdentifier && ok
+}
+
+func (p *parser) isLiteralKeyword(tok token) bool {
+	return p.isKeyword(tok, "true", "false", "null")
+}
+
+// parseComparison parses a comparison, or a value when no comparison
+// operator follows it.
+func (p *parser) parseComparison() (Node, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.isOperator(p.peek()) {
+		return left, nil
+	}
+
+	if literal, ok := left.(*LiteralNode); ok {
+		return nil, &SyntaxError{Position: literal.Position, Message: fmt.Sprintf("expected property name or function but found %s", literal)}
+	}
+
+	if left, err = p.value(left); err != nil {
+		return nil, err
+	}
+
+	op := p.advance()
+
+	if strings.EqualFold(op.Literal, "in") {
+		list, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+
+		return &ComparisonNode{Position: left.Pos(), Left: left, Operator: "in", Right: list}, nil
+	}
+
+	right, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+
+	if right, err = p.value(right); err != nil {
+		return nil, err
+	}
+
+	return &ComparisonNode{Position: left.Pos(), Left: left, Operator: strings.ToLower(op.Literal), Right: right}, nil
+}
+
+func (p *parser) parseAdditive() (Node, error) {
+	return p.parseArithmetic([]string{"add", "sub"}, p.parseTerm)
+}
+
+func (p *parser) parseTerm() (Node, error) {
+	return p.parseArithmetic([]string{"mul", "div", "mod"}, p.parsePrimary)
+}
+
+// parseArithmetic parses a left associative chain of values joined by any of
+// operators.
+func (p *parser) parseArithmetic(operators []string, parseOperand func() (Node, error)) (Node, error) {
+	left, err := parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isKeyword(p.peek(), operators...) {
+		if left, err = p.value(left); err != nil {
+			return nil, err
+		}
+
+		op := p.advance()
+
+		right, err := parseOperand()
+		if err != nil {
+			return nil, err
+		}
+
+		if right, err = p.value(right); err != nil {
+			return nil, err
+		}
+
+		left = &ArithmeticNode{Position: left.Pos(), Left: left, Operator: strings.ToLower(op.Literal), Right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parsePrimary() (Node, error) {
+	tok := p.peek()
+
+	switch {
+	case tok.Type == tokenLParen:
+		open := p.advance()
+
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if tok := p.advance(); tok.Type != tokenRParen {
+			return nil, p.unexpected(tok, fmt.Sprintf("')' to close '(' at position %d", open.Position))
+		}
+
+		return node, nil
+	case tok.Type == tokenIdentifier && p.tokens[p.position+1].Type == tokenLParen:
+		return p.parseCall()
+	case tok.Type == tokenIdentifier && !p.isLiteralKeyword(tok):
+		property, err := p.parseProperty()
+		if err != nil {
+			return nil, err
+		}
+
+		isLambda := strings.EqualFold(property.Name, "any") || strings.EqualFold(property.Name, "all")
+		if isLambda && len(property.Path) > 0 && p.peek().Type == tokenLParen {
+			return p.parseLambda(property)
+		}
+
+		return property, nil
+	}
+
+	return p.parseLiteral("property name, function or value")
+}
+
+func (p *parser) parseCall() (Node, error) {
+	name := p.advance()
+
+	function, ok := filterFunctions[strings.ToLower(name.Literal)]
+	if !ok {
+		return nil, &SyntaxError{Position: name.Position, Message: fmt.Sprintf("unknown function '%s'", name.Literal)}
+	}
+
+	call := &CallNode{Position: name.Position, Function: strings.ToLower(name.Literal)}
+
+	open := p.advance()
+
+	if p.peek().Type != tokenRParen {
+		for {
+			argument, err := p.parseAdditive()
+			if err != nil {
+				return nil, err
+			}
+
+			if argument, err = p.value(argument); err != nil {
+				return nil, err
+			}
+
+			call.Arguments = append(call.Arguments, argument)
+
+			if p.peek().Type != tokenComma {
+				break
+			}
+
+			p.advance()
+		}
+	}
+
+	if tok := p.advance(); tok.Type != tokenRParen {
+		return nil, p.unexpected(tok, fmt.Sprintf("',' or ')' to close '(' at position %d", open.Position))
+	}
+
+	if required := len(function.Params) - function.Optional; len(call.Arguments) < required || len(call.Arguments) > len(function.Params) {
+		return nil, &SyntaxError{Position: name.Position, Message: fmt.Sprintf("function '%s' expects %s but found %d", call.Function, function.arity(), len(call.Arguments))}
+	}
+
+	return call, nil
+}
+
+// parseLambda parses the arguments of an any or all operator, where property
+// is the collection path ending with the operator.
+func (p *parser) parseLambda(property *PropertyNode) (Node, error) {
+	lambda := &LambdaNode{
+		Position:   property.Position,
+		Collection: &PropertyNode{Position: property.Position, Path: property.Path[:len(property.Path)-1], Name: property.Path[len(property.Path)-1]},
+		Function:   strings.ToLower(property.Name),
+	}
+
+	open := p.advance()
+
+	if p.peek().Type == tokenRParen && lambda.Function == "any" {
+		p.advance()
+		return lambda, nil
+	}
+
+	variable := p.advance()
+	if variable.Type != tokenIdentifier {
+		return nil, p.unexpected(variable, "lambda variable")
+	}
+
+	if tok := p.advance(); tok.Type != tokenColon {
+		return nil, p.unexpected(tok, "':'")
+	}
+
+	predicate, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if predicate, err = p.condition(predicate); err != nil {
+		return nil, err
+	}
+
+	if tok := p.advance(); tok.Type != tokenRParen {
+		return nil, p.unexpected(tok, fmt.Sprintf("')' to close '(' at position %d", open.Position))
+	}
+
+	lambda.Variable = variable.Literal
+	lambda.Predicate = predicate
+
+	return lambda, nil
+}
+
+func (p *parser) parseProperty() (*PropertyNode, error) {
+	tok := p.advance()
+	if tok.Type != tokenIdentifier {
+		return nil, p.unexpected(tok, "property name")
+	}
+
+	property := &PropertyNode{Position: tok.Position, Name: tok.Literal}
+
+	for p.peek().Type == tokenSlash {
+		p.advance()
+
+		tok := p.advance()
+		if tok.Type != tokenIdentifier {
+			return nil, p.unexpected(tok, "property name")
+		}
+
+		property.Path = append(property.Path, property.Name)
+		property.Name = tok.Literal
+	}
+
+	return property, nil
+}
+
+// parseList parses the list of values of an in operator.
+func (p *parser) parseList() (*ListNode, error) {
+	open := p.advance()
+	if open.Type != tokenLParen {
+		return nil, p.unexpected(open, "'(' to start a list of values")
+	}
+
+	list := &ListNode{Position: open.Position}
+
+	for {
+		value, err := p.parseLiteral("value")
+		if err != nil {
+			return nil, err
+		}
+
+		list.Values = append(list.Values, value)
+
+		if p.peek().Type != tokenComma {
+			break
+		}
+
+		p.advance()
+	}
+
+	if tok := p.advance(); tok.Type != tokenRParen {
+		return nil, p.unexpected(tok, fmt.Sprintf("',' or ')' to close '(' at position %d", open.Position))
+	}
+
+	return list, nil
+}
+
+// parseLiteral parses a literal, reporting anything else as not being what
+// was expected.
+func (p *parser) parseLiteral(expected string) (*LiteralNode, error) {
+	tok := p.advance()
+
+	switch {
+	case tok.Type == tokenString:
+		return &LiteralNode{Position: tok.Position, Kind: StringLiteral, Value: tok.Literal}, nil
+	case tok.Type == tokenNumber:
+		return &LiteralNode{Position: tok.Position, Kind: NumberLiteral, Value: tok.Literal}, nil
+	case tok.Type == tokenDate:
+		return &LiteralNode{Position: tok.Position, Kind: DateLiteral, Value: tok.Literal}, nil
+	case tok.Type == tokenDateTime:
+		return &LiteralNode{Position: tok.Position, Kind: DateTimeLiteral, Value: tok.Literal}, nil
+	case tok.Type == tokenGUID:
+		return &LiteralNode{Position: tok.Position, Kind: GUIDLiteral, Value: tok.Literal}, nil
+	case p.isKeyword(tok, "true", "false"):
+		return &LiteralNode{Position: tok.Position, Kind: BooleanLiteral, Value: strings.ToLower(tok.Literal)}, nil
+	case p.isKeyword(tok, "null"):
+		return &LiteralNode{Position: tok.Position, Kind: NullLiteral, Value: "null"}, nil
+	}
+
+	return nil, p.unexpected(tok, expected)
+}
@@ -0,0 +1,294 @@
+package goatquery
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ParseFilterComparison(t *testing.T) {
+	node, err := ParseFilter("firstname eq 'goat'")
+
+	assert.NoError(t, err)
+	assert.Equal(t, &ComparisonNode{
+		Position: 0,
+		Left:     &PropertyNode{Position: 0, Name: "firstname"},
+		Operator: "eq",
+		Right:    &LiteralNode{Position: 13, Kind: StringLiteral, Value: "goat"},
+	}, node)
+}
+
+func Test_ParseFilterLiteralKinds(t *testing.T) {
+	node, err := ParseFilter("age eq 21 and contributor eq true")
+
+	assert.NoError(t, err)
+
+	logical := node.(*LogicalNode)
+	assert.Equal(t, "and", logical.Operator)
+	assert.Equal(t, NumberLiteral, logical.Left.(*ComparisonNode).Right.(*LiteralNode).Kind)
+	assert.Equal(t, BooleanLiteral, logical.Right.(*ComparisonNode).Right.(*LiteralNode).Kind)
+}
+
+func Test_ParseFilterTypedLiterals(t *testing.T) {
+	tests := []struct {
+		literal string
+		kind    LiteralKind
+		value   string
+	}{
+		{"21", NumberLiteral, "21"},
+		{"-1.5", NumberLiteral, "-1.5"},
+		{"TRUE", BooleanLiteral, "true"},
+		{"null", NullLiteral, "null"},
+		{"2024-01-31", DateLiteral, "2024-01-31"},
+		{"2024-01-31T10:00:00Z", DateTimeLiteral, "2024-01-31T10:00:00Z"},
+		{"2024-01-31T10:00:00.5+01:00", DateTimeLiteral, "2024-01-31T10:00:00.5+01:00"},
+		{"1b4e28ba-2fa1-11d2-883f-0016d3cca427", GUIDLiteral, "1b4e28ba-2fa1-11d2-883f-0016d3cca427"},
+		{"e4c1b28b-2fa1-11d2-883f-0016d3cca427", GUIDLiteral, "e4c1b28b-2fa1-11d2-883f-0016d3cca427"},
+		{"'O''Brien'", StringLiteral, "O'Brien"},
+		{"''''", StringLiteral, "'"},
+	}
+
+	for _, test := range tests {
+		node, err := ParseFilter("x eq " + test.literal + " and y eq 1")
+
+		if assert.NoError(t, err, test.literal) {
+			value := node.(*LogicalNode).Left.(*ComparisonNode).Right
+			assert.Equal(t, &LiteralNode{Position: 5, Kind: test.kind, Value: test.value}, value, test.literal)
+		}
+	}
+}
+
+func Test_ParseFilterStringRoundTripsEscapedQuotes(t *testing.T) {
+	node, err := ParseFilter("lastname eq 'O''Brien'")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "lastname eq 'O''Brien'", node.String())
+}
+
+func Test_ParseFilterAndBindsTighterThanOr(t *testing.T) {
+	node, err := ParseFilter("firstname eq 'a' or lastname eq 'b' and age eq 1")
+
+	assert.NoError(t, err)
+
+	logical := node.(*LogicalNode)
+	assert.Equal(t, "or", logical.Operator)
+	assert.Equal(t, "and", logical.Right.(*LogicalNode).Operator)
+}
+
+func Test_ParseFilterIsLeftAssociative(t *testing.T) {
+	node, err := ParseFilter("firstname eq 'a' and lastname eq 'b' and age eq 1")
+
+	assert.NoError(t, err)
+
+	logical := node.(*LogicalNode)
+	assert.Equal(t, "and", logical.Operator)
+	assert.IsType(t, &ComparisonNode{}, logical.Right)
+	assert.Equal(t, "and", logical.Left.(*LogicalNode).Operator)
+}
+
+func Test_ParseFilterParentheses(t *testing.T) {
+	node, err := ParseFilter("(firstname eq 'a' or lastname eq 'b') and age eq 1")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "(firstname eq 'a' or lastname eq 'b') and age eq 1", node.String())
+
+	logical := node.(*LogicalNode)
+	assert.Equal(t, "and", logical.Operator)
+	assert.Equal(t, "or", logical.Left.(*LogicalNode).Operator)
+}
+
+func Test_ParseFilterNot(t *testing.T) {
+	node, err := ParseFilter("not firstname eq 'a' and not (lastname eq 'b' or age eq 1)")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "not firstname eq 'a' and not (lastname eq 'b' or age eq 1)", node.String())
+
+	logical := node.(*LogicalNode)
+	assert.IsType(t, &ComparisonNode{}, logical.Left.(*NotNode).Operand)
+	assert.IsType(t, &LogicalNode{}, logical.Right.(*NotNode).Operand)
+}
+
+func Test_ParseFilterConjunctionInsideString(t *testing.T) {
+	node, err := ParseFilter("firstname eq ' and ' or lastname eq ' and or '")
+
+	assert.NoError(t, err)
+
+	logical := node.(*LogicalNode)
+	assert.Equal(t, " and ", logical.Left.(*ComparisonNode).Right.(*LiteralNode).Value)
+	assert.Equal(t, " and or ", logical.Right.(*ComparisonNode).Right.(*LiteralNode).Value)
+}
+
+func Test_ParseFilterKeywordsAreCaseInsensitive(t *testing.T) {
+	node, err := ParseFilter("firstname EQ 'goat' AND contributor eq TRUE")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "firstname eq 'goat' and contributor eq true", node.String())
+}
+
+func Test_ParseFilterPropertyPath(t *testing.T) {
+	node, err := ParseFilter("address/country/name eq 'UK'")
+
+	assert.NoError(t, err)
+
+	property := node.(*ComparisonNode).Left.(*PropertyNode)
+	assert.Equal(t, []string{"address", "country"}, property.Path)
+	assert.Equal(t, "name", property.Name)
+	assert.Equal(t, "address/country/name", property.String())
+}
+
+func Test_ParseFilterLambda(t *testing.T) {
+	node, err := ParseFilter("permissions/any(p: p/name eq 'admin') and tags/ANY()")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "permissions/any(p: p/name eq 'admin') and tags/any()", node.String())
+
+	lambda := node.(*LogicalNode).Left.(*LambdaNode)
+	assert.Equal(t, "permissions", lambda.Collection.Name)
+	assert.Equal(t, "any", lambda.Function)
+	assert.Equal(t, "p", lambda.Variable)
+	assert.Equal(t, []string{"p"}, lambda.Predicate.(*ComparisonNode).Left.(*PropertyNode).Path)
+}
+
+func Test_ParseFilterFunctions(t *testing.T) {
+	node, err := ParseFilter("StartsWith(firstname, 'go') and length(address/postcode) gt 3")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "startswith(firstname, 'go') and length(address/postcode) gt 3", node.String())
+
+	logical := node.(*LogicalNode)
+	assert.Equal(t, &CallNode{
+		Position: 0,
+		Function: "startswith",
+		Arguments: []Node{
+			&PropertyNode{Position: 11, Name: "firstname"},
+			&LiteralNode{Position: 22, Kind: StringLiteral, Value: "go"},
+		},
+	}, logical.Left)
+
+	comparison := logical.Right.(*ComparisonNode)
+	assert.Equal(t, "length", comparison.Left.(*CallNode).Function)
+	assert.Equal(t, 32, comparison.Position)
+}
+
+func Test_ParseFilterNestedFunctions(t *testing.T) {
+	node, err := ParseFilter("substring(tolower(firstname), 1) eq concat(lastname, 'a')")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "substring(tolower(firstname), 1) eq concat(lastname, 'a')", node.String())
+	assert.IsType(t, &CallNode{}, node.(*ComparisonNode).Right)
+}
+
+func Test_ParseFilterArithmetic(t *testing.T) {
+	node, err := ParseFilter("age add 1 mul 2 gt 10")
+
+	assert.NoError(t, err)
+	assert.Equal(t, &ComparisonNode{
+		Position: 0,
+		Left: &ArithmeticNode{
+			Position: 0,
+			Left:     &PropertyNode{Position: 0, Name: "age"},
+			Operator: "add",
+			Right: &ArithmeticNode{
+				Position: 8,
+				Left:     &LiteralNode{Position: 8, Kind: NumberLiteral, Value: "1"},
+				Operator: "mul",
+				Right:    &LiteralNode{Position: 14, Kind: NumberLiteral, Value: "2"},
+			},
+		},
+		Operator: "gt",
+		Right:    &LiteralNode{Position: 19, Kind: NumberLiteral, Value: "10"},
+	}, node)
+}
+
+func Test_ParseFilterArithmeticGrouping(t *testing.T) {
+	filters := []string{
+		"(age add 1) mul 2 gt 10",
+		"age sub (1 sub age) eq 0",
+		"age sub 1 sub age eq 0",
+		"age mul 2 add 1 eq length(firstname) mod 3",
+		"not (age div 2 gt 1 or age eq 1)",
+	}
+
+	for _, filter := range filters {
+		node, err := ParseFilter(filter)
+
+		if assert.NoError(t, err, filter) {
+			assert.Equal(t, filter, node.String())
+		}
+	}
+}
+
+func Test_ParseFilterIn(t *testing.T) {
+	node, err := ParseFilter("status IN ('active','pending')")
+
+	assert.NoError(t, err)
+	assert.Equal(t, &ComparisonNode{
+		Position: 0,
+		Left:     &PropertyNode{Position: 0, Name: "status"},
+		Operator: "in",
+		Right: &ListNode{Position: 10, Values: []*LiteralNode{
+			{Position: 11, Kind: StringLiteral, Value: "active"},
+			{Position: 20, Kind: StringLiteral, Value: "pending"},
+		}},
+	}, node)
+	assert.Equal(t, "status in ('active', 'pending')", node.String())
+}
+
+func Test_ParseFilterSyntaxErrors(t *testing.T) {
+	tests := []struct {
+		filter   string
+		position int
+	}{
+		{filter: "firstname", position: 9},
+		{filter: "firstname eq", position: 12},
+		{filter: "firstname equals 'goat'", position: 10},
+		{filter: "firstname eq 'goat", position: 13},
+		{filter: "firstname eq 'goat' lastname eq 'query'", position: 20},
+		{filter: "firstname eq 'goat' and", position: 23},
+		{filter: "firstname eq 'goat' and ; drop table users", position: 24},
+		{filter: "'goat' eq firstname", position: 0},
+		{filter: "(firstname eq 'goat'", position: 20},
+		{filter: "firstname eq 'goat')", position: 19},
+		{filter: "()", position: 1},
+		{filter: "not", position: 3},
+		{filter: "address/'AB1'", position: 8},
+		{filter: "permissions/all()", position: 16},
+		{filter: "permissions/any(p p/name eq 'admin')", position: 18},
+		{filter: "permissions/any(p: p/name eq 'admin'", position: 36},
+		{filter: "age eq 1.2.3", position: 7},
+		{filter: "createdAt gt 2024-01", position: 13},
+		{filter: "createdAt gt 2024-01-31T10", position: 13},
+		{filter: "id eq 1b4e28ba-2fa1-11d2-883f", position: 6},
+		{filter: "lastname eq 'O'Brien'", position: 20},
+		{filter: "unknown(firstname) eq 1", position: 0},
+		{filter: "length(firstname, lastname) gt 1", position: 0},
+		{filter: "substring(firstname) eq 'a'", position: 0},
+		{filter: "length(firstname gt 1", position: 17},
+		{filter: "startswith(firstname, )", position: 22},
+		{filter: "length(firstname) gt", position: 20},
+		{filter: "createdAt lt now(1)", position: 13},
+		{filter: "age mul", position: 7},
+		{filter: "age add 1", position: 9},
+		{filter: "not age", position: 7},
+		{filter: "(age add 1)", position: 11},
+		{filter: "firstname eq 'a' and age", position: 24},
+		{filter: "age add (firstname eq 'a') gt 1", position: 9},
+		{filter: "length(firstname eq 'a') gt 1", position: 17},
+		{filter: "length(firstname)", position: 17},
+		{filter: "age in 1", position: 7},
+		{filter: "age in ()", position: 8},
+		{filter: "age in (1 2)", position: 10},
+		{filter: "age in (1,)", position: 10},
+		{filter: "age in (age)", position: 8},
+		{filter: "age in (1", position: 9},
+	}
+
+	for _, test := range tests {
+		_, err := ParseFilter(test.filter)
+
+		var syntaxErr *SyntaxError
+		if assert.ErrorAs(t, err, &syntaxErr, test.filter) {
+			assert.Equal(t, test.position, syntaxErr.Position, test.filter)
+		}
+	}
+}
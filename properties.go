@@ -0,0 +1,157 @@
+package goatquery
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// property is a field of a model that clients refer to by its json name.
+type property struct {
+	Name string
+	// Field is the struct field, with an index relative to the model.
+	Field reflect.StructField
+	// Column is the column named by the gorm tag or naming strategy, which
+	// relations have too although they aren't stored in one.
+	Column string
+	// SchemaField is the gorm field of Column, or nil when there is none.
+	SchemaField *schema.Field
+	// Relation is set when the field is a relation.
+	Relation     *schema.Relationship
+	Capabilities capability
+	Case         caseSensitivity
+}
+
+func (p *property) allows(c capability) bool {
+	return p.Capabilities&c != 0
+}
+
+// modelProperties holds the properties of a model, parsed once from its
+// schema and shared by every query of the model.
+type modelProperties struct {
+	schema     *schema.Schema
+	properties map[string]*property
+	// relations are the related model types of the relations by their json
+	// keys, including relations without a json name.
+	relations map[string]reflect.Type
+	// unselectable are the json keys of the fields that cannot be selected,
+	// including fields without a json name.
+	unselectable []string
+}
+
+// propertyRegistry caches the *modelProperties of every schema. Schemas are
+// cached by gorm, so each model has one entry per naming strategy in use.
+var propertyRegistry sync.Map
+
+// propertiesOf returns the properties of the model of sch, where namer is the
+// naming strategy sch was parsed with.
+func propertiesOf(sch *schema.Schema, namer schema.Namer) *modelProperties {
+	if cached, ok := propertyRegistry.Load(sch); ok {
+		return cached.(*modelProperties)
+	}
+
+	m := &modelProperties{
+		schema:     sch,
+		properties: map[string]*property{},
+		relations:  map[string]reflect.Type{},
+	}
+	m.add(sch.ModelType, nil, namer)
+
+	cached, _ := propertyRegistry.LoadOrStore(sch, m)
+
+	return cached.(*modelProperties)
+}
+
+// modelPropertiesOf returns the properties of modelType as queried through db.
+func modelPropertiesOf(db *gorm.DB, modelType reflect.Type) (*modelProperties, error) {
+	sch, err := parseSchema(db, modelType)
+	if err != nil {
+		return nil, err
+	}
+
+	return propertiesOf(sch, db.NamingStrategy), nil
+}
+
+// responseProperties returns the properties of modelType used to build responses.
+func responseProperties(modelType reflect.Type) (*modelProperties, error) {
+	sch, err := responseSchema(modelType)
+	if err != nil {
+		return nil, err
+	}
+
+	return propertiesOf(sch, schema.NamingStrategy{}), nil
+}
+
+// add adds the fields of t, which is embedded in the model at index, looking
+// through embedded structs. The first field with a json name wins, as it does
+// when the model is encoded to json.
+func (m *modelProperties) add(t reflect.Type, index []int, namer schema.Namer) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		f.Index = append(append([]int{}, index...), i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0] // use split to ignore tag "options" like omitempty, etc.
+
+		switch {
+		case name == "-" || !f.IsExported():
+			continue
+		case f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct:
+			m.add(f.Type, f.Index, namer)
+			continue
+		}
+
+		key := name
+		if key == "" {
+			key = f.Name
+		}
+
+		tag := f.Tag.Get("goatquery")
+		capabilities := parseCapabilities(tag)
+		if capabilities&capSelect == 0 {
+			m.unselectable = append(m.unselectable, key)
+		}
+
+		rel := m.schema.Relationships.Relations[f.Name]
+		if _, ok := m.relations[key]; !ok && rel != nil {
+			m.relations[key] = rel.FieldSchema.ModelType
+		}
+
+		// fields without a json name cannot be referred to by clients
+		if _, ok := m.properties[name]; ok || name == "" {
+			continue
+		}
+
+		p := &property{
+			Name:         name,
+			Field:        f,
+			Column:       columnName(namer, m.schema.Table, f),
+			Relation:     rel,
+			Capabilities: capabilities,
+			Case:         parseCaseSensitivity(tag),
+		}
+
+		if field, ok := m.schema.FieldsByDBName[p.Column]; ok && field.Name == f.Name {
+			p.SchemaField = field
+		}
+
+		m.properties[name] = p
+	}
+}
+
+// lookup returns the property with the json name name.
+func (m *modelProperties) lookup(name string) (*property, bool) {
+	p, ok := m.properties[name]
+	return p, ok
+}
+
+// columnName returns the column of the field f of a model with the table tableName.
+func columnName(namer schema.Namer, tableName string, f reflect.StructField) string {
+	settings := schema.ParseTagSetting(f.Tag.Get("gorm"), ";")
+	if settings["COLUMN"] != "" {
+		return settings["COLUMN"]
+	}
+
+	return namer.ColumnName(tableName, f.Name)
+}
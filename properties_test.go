@@ -0,0 +1,221 @@
+package goatquery
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func Test_PropertiesOfModel(t *testing.T) {
+	properties, err := modelPropertiesOf(DB, reflect.TypeOf(User{}))
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		column   string
+		index    []int
+		relation bool
+	}{
+		{"id", "id", []int{0, 0}, false},
+		{"userName", "display_name", []int{4}, false},
+		{"gender", "person_sex", []int{5}, false},
+		{"address", "address", []int{15}, true},
+		{"permissions", "permissions", []int{18}, true},
+	}
+
+	for _, test := range tests {
+		p, ok := properties.lookup(test.name)
+		if assert.True(t, ok, test.name) {
+			assert.Equal(t, test.column, p.Column, test.name)
+			assert.Equal(t, test.index, p.Field.Index, test.name)
+			assert.Equal(t, test.relation, p.Relation != nil, test.name)
+		}
+	}
+
+	for _, name := range []string{"", "-", "AddressId", "Firstname"} {
+		_, ok := properties.lookup(name)
+		assert.False(t, ok, name)
+	}
+
+	assert.Equal(t, []string{"password"}, properties.unselectable)
+	assert.Equal(t, reflect.TypeOf(Address{}), properties.relations["address"])
+}
+
+func Test_PropertiesAreParsedOnce(t *testing.T) {
+	results := make(chan *modelProperties, 10)
+
+	var wg sync.WaitGroup
+	for i := 0; i < cap(results); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			properties, err := responseProperties(reflect.TypeOf(Country{}))
+			assert.NoError(t, err)
+			results <- properties
+		}()
+	}
+
+	wg.Wait()
+	close(results)
+
+	first := <-results
+	for properties := range results {
+		assert.Same(t, first, properties)
+	}
+}
+
+func Test_GetGormColumnNameByJsonTag(t *testing.T) {
+	tests := []struct {
+		property string
+		column   string
+	}{
+		{"id", "id"},
+		{"userName", "display_name"},
+		{"gender", "person_sex"},
+		{"unknown", "unknown"},
+		{"-", "-"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.column, GetGormColumnNameByJsonTag(DB.NamingStrategy, "users", reflect.TypeOf(User{}), test.property), test.property)
+	}
+}
+
+var benchmarkApplyQuery = Query{
+	Top:     10,
+	Filter:  "firstname eq 'goat' and age gt 21 and address/postcode eq 'AB1'",
+	OrderBy: "lastname desc, firstname",
+	Select:  "id,firstname,lastname,email,userName",
+}
+
+// benchmarkProperties are the properties benchmarkApplyQuery refers to.
+var benchmarkProperties = []string{"firstname", "age", "address", "lastname", "firstname", "id", "firstname", "lastname", "email", "userName"}
+
+var benchmarkResponseQuery = Query{Select: "id,firstname,lastname,email,userName,gender,age"}
+
+func benchmarkResponseData() []User {
+	data := make([]User, 100)
+	for i := range data {
+		data[i] = User{Base: Base{Id: uuid.New()}, Firstname: "John", Lastname: "Doe", Email: "John.Doe@email.com"}
+	}
+
+	return data
+}
+
+// reflectProperty finds the field of t with the json name property by
+// scanning its fields, as every query did before the property registry.
+func reflectProperty(t reflect.Type, property string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		v := strings.Split(f.Tag.Get("json"), ",")[0]
+		if v == property {
+			return f, true
+		}
+
+		if f.Anonymous && v == "" && f.Type.Kind() == reflect.Struct {
+			if embedded, ok := reflectProperty(f.Type, property); ok {
+				embedded.Index = append([]int{i}, embedded.Index...)
+				return embedded, true
+			}
+		}
+	}
+
+	return reflect.StructField{}, false
+}
+
+func Benchmark_Apply(b *testing.B) {
+	db := DB.Session(&gorm.Session{DryRun: true})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, _, err := Apply(db.Model(&User{}), benchmarkApplyQuery, nil, nil, &[]User{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func Benchmark_PropertyLookup(b *testing.B) {
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		properties, err := modelPropertiesOf(DB, reflect.TypeOf(User{}))
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		for _, name := range benchmarkProperties {
+			if p, ok := properties.lookup(name); !ok || !p.allows(capFilter) || p.Column == "" {
+				b.Fatal(name)
+			}
+		}
+	}
+}
+
+// Benchmark_PropertyLookupReflection resolves the properties of
+// Benchmark_PropertyLookup without the registry.
+func Benchmark_PropertyLookupReflection(b *testing.B) {
+	t := reflect.TypeOf(User{})
+	namer := DB.NamingStrategy
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		tableName := namer.TableName(t.Name())
+
+		for _, name := range benchmarkProperties {
+			f, ok := reflectProperty(t, name)
+			if !ok || parseCapabilities(f.Tag.Get("goatquery"))&capFilter == 0 || columnName(namer, tableName, f) == "" {
+				b.Fatal(name)
+			}
+		}
+	}
+}
+
+func Benchmark_BuildPagedResponse(b *testing.B) {
+	data := benchmarkResponseData()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		BuildPagedResponse(data, benchmarkResponseQuery, nil)
+	}
+}
+
+// Benchmark_BuildPagedResponseReflection builds the values of
+// Benchmark_BuildPagedResponse without the registry, finding the field of
+// every selected property for every row.
+func Benchmark_BuildPagedResponseReflection(b *testing.B) {
+	data := benchmarkResponseData()
+	t := reflect.TypeOf(User{})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		result := make([]map[string]interface{}, len(data))
+		for j, row := range data {
+			obj := map[string]interface{}{}
+			v := reflect.ValueOf(row)
+
+			for _, item := range splitList(benchmarkResponseQuery.Select) {
+				f, ok := reflectProperty(t, item.Value)
+				if ok && parseCapabilities(f.Tag.Get("goatquery"))&capSelect != 0 {
+					obj[item.Value] = v.FieldByIndex(f.Index).Interface()
+				}
+			}
+
+			result[j] = obj
+		}
+	}
+}
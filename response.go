@@ -2,14 +2,40 @@ package goatquery
 
 import (
 	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
 	"reflect"
+	"strconv"
 	"strings"
 )
 
 func BuildPagedResponse[T any](res []T, query Query, totalCount *int64) PagedResponse[map[string]interface{}] {
+	// a page before a cursor is loaded in reverse
+	if c, _ := decodeCursor(query.Cursor); c.Backward {
+		reversed := make([]T, len(res))
+		for i, row := range res {
+			reversed[len(res)-1-i] = row
+		}
+
+		res = reversed
+	}
+
+	response := buildPagedResponse(res, query, totalCount)
+	response.NextCursor, response.PrevCursor = pageCursors(res, query)
+
+	return response
+}
+
+func buildPagedResponse[T any](res []T, query Query, totalCount *int64) PagedResponse[map[string]interface{}] {
 	result := make([]map[string]interface{}, len(res))
+	modelType := modelTypeOf(res)
 
-	selectedProperties := strings.Split(strings.TrimSpace(query.Select), ",")
+	// Apply rejects an invalid Expand, so there is nothing to report here
+	var expanded []expandItem
+	if query.Expand != "" {
+		expanded, _ = parseExpand(query.Expand, 0)
+	}
 
 	if query.Select == "" {
 		bytes, _ := json.Marshal(res)
@@ -18,26 +44,45 @@ func BuildPagedResponse[T any](res []T, query Query, totalCount *int64) PagedRes
 			return PagedResponse[map[string]interface{}]{Value: result, Count: totalCount}
 		}
 
+		for _, obj := range result {
+			shapeExpanded(obj, modelType, expanded)
+		}
+
 		return PagedResponse[map[string]interface{}]{Value: result, Count: totalCount}
 	}
 
+	properties, err := responseProperties(modelType)
+	if err != nil {
+		return PagedResponse[map[string]interface{}]{Value: result, Count: totalCount}
+	}
+
+	// resolve the selected json properties to their fields once, rather than per row
+	selected := map[string][]int{}
+	for _, item := range splitList(query.Select) {
+		if p, ok := properties.lookup(item.Value); ok && p.allows(capSelect) {
+			selected[item.Value] = p.Field.Index
+		}
+	}
+
+	relations := properties.relations
+	for _, item := range expanded {
+		if p, ok := properties.lookup(item.Property); ok && p.allows(capSelect) && p.Relation != nil {
+			selected[item.Property] = p.Field.Index
+		}
+	}
+
 	for i, obj := range res {
-		newObj := make(map[string]interface{})
-		v := reflect.ValueOf(obj)
+		newObj := make(map[string]interface{}, len(selected))
+		v := reflect.Indirect(reflect.ValueOf(obj))
 
 		// map over selected properties
-		for _, p := range selectedProperties {
-			property := strings.TrimSpace(p)
-			field, _ := v.Type().FieldByNameFunc(func(p string) bool {
-				return strings.EqualFold(property, p)
-			})
-			name := field.Tag.Get("json")
-
-			if name != "" && name != "-" {
-				// '-' in the json tag means to not return that property
-				newObj[name] = v.FieldByNameFunc(func(p string) bool {
-					return strings.EqualFold(property, p)
-				}).Interface()
+		for name, index := range selected {
+			newObj[name] = v.FieldByIndex(index).Interface()
+		}
+
+		for _, item := range expanded {
+			if relType, ok := relations[item.Property]; ok {
+				newObj[item.Property] = shapeRelated(toJSON(newObj[item.Property]), relType, item)
 			}
 		}
 
@@ -46,3 +91,146 @@ func BuildPagedResponse[T any](res []T, query Query, totalCount *int64) PagedRes
 
 	return PagedResponse[map[string]interface{}]{Value: result, Count: totalCount}
 }
+
+// WithNextLink returns r with the NextLink to the page after it, made from
+// requestURL, the url the page was requested with, and query, the Query it was
+// loaded with. Skip is advanced by Top, or the cursor set to NextCursor when
+// paging with a cursor, keeping the names the url uses for them, e.g. "$skip".
+// A parameter the url doesn't have yet is named with a "$" prefix when the
+// other parameters of the url have one.
+//
+// There is no NextLink when the page is the last one: when it has fewer rows
+// than Top, when Count shows there are no rows after it or, since the size of
+// a page is unknown without it, when Top is not set. When Top comes from
+// WithMaxTop, pass the query returned by ApplyWith.
+func (r PagedResponse[T]) WithNextLink(requestURL *url.URL, query Query) PagedResponse[T] {
+	r.NextLink = ""
+	if requestURL == nil {
+		return r
+	}
+
+	values := requestURL.Query()
+
+	switch {
+	case query.Cursor != "":
+		if r.NextCursor == "" {
+			return r
+		}
+
+		setQueryParam(values, "cursor", r.NextCursor)
+	case query.Top > 0:
+		skip := query.Skip + query.Top
+		if len(r.Value) < query.Top || (r.Count != nil && int64(skip) >= *r.Count) {
+			return r
+		}
+
+		setQueryParam(values, "skip", strconv.Itoa(skip))
+	default:
+		return r
+	}
+
+	link := *requestURL
+	link.RawQuery = values.Encode()
+	r.NextLink = link.String()
+
+	return r
+}
+
+// setQueryParam sets the query parameter name of values, matching an existing
+// parameter regardless of case or a "$" prefix. A new parameter follows the
+// "$" prefix of the others, as in "$top" and "$skip".
+func setQueryParam(values url.Values, name string, value string) {
+	prefix := ""
+	for key := range values {
+		if strings.EqualFold(strings.TrimPrefix(key, "$"), name) {
+			values.Set(key, value)
+			return
+		}
+
+		if strings.HasPrefix(key, "$") {
+			prefix = "$"
+		}
+	}
+
+	values.Set(prefix+name, value)
+}
+
+// shapeExpanded removes the relations of modelType that were not expanded
+// and the properties that cannot be selected from obj, and applies the
+// options of the expanded relations.
+func shapeExpanded(obj map[string]interface{}, modelType reflect.Type, expanded []expandItem) {
+	properties, err := responseProperties(modelType)
+	if err != nil {
+		return
+	}
+
+	for _, name := range properties.unselectable {
+		delete(obj, name)
+	}
+
+	for name, relType := range properties.relations {
+		item, ok := findExpandItem(expanded, name)
+		if !ok {
+			delete(obj, name)
+			continue
+		}
+
+		obj[name] = shapeRelated(obj[name], relType, item)
+	}
+}
+
+// shapeRelated applies the $select and $expand options of item to the json
+// value of a relation of type modelType.
+func shapeRelated(value interface{}, modelType reflect.Type, item expandItem) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if item.Options.Select != "" {
+			keep := map[string]bool{}
+			for _, s := range splitList(item.Options.Select) {
+				keep[s.Value] = true
+			}
+
+			for _, e := range item.Expanded {
+				keep[e.Property] = true
+			}
+
+			for name := range v {
+				if !keep[name] {
+					delete(v, name)
+				}
+			}
+		}
+
+		shapeExpanded(v, modelType, item.Expanded)
+	case []interface{}:
+		for i := range v {
+			v[i] = shapeRelated(v[i], modelType, item)
+		}
+	}
+
+	return value
+}
+
+// toJSON converts value into the generic form it is decoded into from json.
+func toJSON(value interface{}) interface{} {
+	var result interface{}
+
+	bytes, _ := json.Marshal(value)
+	if err := json.Unmarshal(bytes, &result); err != nil {
+		return nil
+	}
+
+	return result
+}
+
+// BuildErrorResponse converts an error returned by Apply into a response body.
+// Errors that are not a *QueryError are reported as an internal server error
+// without exposing their message.
+func BuildErrorResponse(err error) QueryErrorResponse {
+	var queryErr *QueryError
+	if errors.As(err, &queryErr) {
+		return QueryErrorResponse{Status: uint(queryErr.Status), Message: queryErr.Message}
+	}
+
+	return QueryErrorResponse{Status: http.StatusInternalServerError, Message: http.StatusText(http.StatusInternalServerError)}
+}
@@ -1,6 +1,9 @@
 package goatquery
 
 import (
+	"errors"
+	"net/http"
+	"net/url"
 	"testing"
 
 	"github.com/google/uuid"
@@ -62,3 +65,147 @@ func Test_SelectIntTypeReturnsCorrectValue(t *testing.T) {
 
 	assert.Equal(t, age, res.Value[0][field])
 }
+
+func Test_BuildErrorResponseFromQueryError(t *testing.T) {
+	maxTop := 2
+	_, _, err := Apply(DB.Model(&User{}), Query{Top: 3}, &maxTop, nil, &[]User{})
+
+	res := BuildErrorResponse(err)
+
+	assert.Equal(t, QueryErrorResponse{Status: http.StatusBadRequest, Message: err.Error()}, res)
+}
+
+func Test_BuildErrorResponseHidesOtherErrors(t *testing.T) {
+	res := BuildErrorResponse(errors.New("database is locked"))
+
+	assert.Equal(t, uint(http.StatusInternalServerError), res.Status)
+	assert.NotContains(t, res.Message, "database")
+}
+
+func Test_SelectReturnsJsonPropertyNames(t *testing.T) {
+	query := Query{Select: "userName, gender"}
+
+	data := []User{
+		{
+			Base:      Base{Id: uuid.New()},
+			UserName:  "goat",
+			PersonSex: "Male",
+		},
+	}
+
+	res := BuildPagedResponse(data, query, nil)
+
+	assert.Equal(t, map[string]interface{}{"userName": "goat", "gender": "Male"}, res.Value[0])
+}
+
+func Test_SelectFromPointerSlice(t *testing.T) {
+	query := Query{Select: "firstname"}
+
+	data := []*User{{Firstname: "John"}}
+
+	res := BuildPagedResponse(data, query, nil)
+
+	assert.Equal(t, map[string]interface{}{"firstname": "John"}, res.Value[0])
+}
+
+func Test_RelationsAreOnlyReturnedWhenExpanded(t *testing.T) {
+	data := []User{
+		{
+			Base:        Base{Id: uuid.New()},
+			Address:     Address{Postcode: "AB1"},
+			Permissions: []UserPermission{{Name: "admin"}},
+		},
+	}
+
+	res := BuildPagedResponse(data, Query{}, nil)
+
+	assert.Contains(t, res.Value[0], "id")
+	assert.NotContains(t, res.Value[0], "address")
+	assert.NotContains(t, res.Value[0], "permissions")
+
+	res = BuildPagedResponse(data, Query{Expand: "permissions($select=name)"}, nil)
+
+	assert.NotContains(t, res.Value[0], "address")
+	assert.Equal(t, []interface{}{map[string]interface{}{"name": "admin"}}, res.Value[0]["permissions"])
+}
+
+func Test_NextLinkAdvancesSkipByTop(t *testing.T) {
+	requestURL, _ := url.Parse("https://example.com/users?$top=2&$skip=4&$filter=age gt 21")
+	query := Query{Top: 2, Skip: 4}
+
+	res := BuildPagedResponse([]User{{}, {}}, query, nil).WithNextLink(requestURL, query)
+
+	assert.Equal(t, "https://example.com/users?%24filter=age+gt+21&%24skip=6&%24top=2", res.NextLink)
+	assert.Equal(t, "https://example.com/users?$top=2&$skip=4&$filter=age gt 21", requestURL.String())
+}
+
+func Test_NextLinkAddsSkip(t *testing.T) {
+	requestURL, _ := url.Parse("/users?top=2")
+	query := Query{Top: 2}
+
+	res := BuildPagedResponse([]User{{}, {}}, query, nil).WithNextLink(requestURL, query)
+
+	assert.Equal(t, "/users?skip=2&top=2", res.NextLink)
+}
+
+func Test_NextLinkAddsPrefixedSkip(t *testing.T) {
+	requestURL, _ := url.Parse("/users?$top=2&$filter=age gt 21")
+	query := Query{Top: 2}
+
+	res := BuildPagedResponse([]User{{}, {}}, query, nil).WithNextLink(requestURL, query)
+
+	assert.Equal(t, "/users?%24filter=age+gt+21&%24skip=2&%24top=2", res.NextLink)
+}
+
+func Test_NextLinkOmittedOnLastPage(t *testing.T) {
+	requestURL, _ := url.Parse("/users?top=2&skip=2")
+	count := int64(4)
+
+	tests := []struct {
+		name  string
+		res   PagedResponse[map[string]interface{}]
+		query Query
+	}{
+		{"short page", BuildPagedResponse([]User{{}}, Query{Top: 2}, nil), Query{Top: 2}},
+		{"no top", BuildPagedResponse([]User{{}, {}}, Query{}, nil), Query{}},
+		{"count reached", BuildPagedResponse([]User{{}, {}}, Query{Top: 2, Skip: 2}, &count), Query{Top: 2, Skip: 2}},
+		{"last cursor page", BuildPagedResponse([]User{{}}, Query{Top: 2, Cursor: CursorStart}, nil), Query{Top: 2, Cursor: CursorStart}},
+	}
+
+	for _, test := range tests {
+		assert.Empty(t, test.res.WithNextLink(requestURL, test.query).NextLink, test.name)
+	}
+}
+
+func Test_NextLinkWithMaxTop(t *testing.T) {
+	for _, name := range []string{"a", "b", "c"} {
+		user := User{Base: Base{Id: uuid.New()}, Firstname: name, Lastname: "NextLinkMaxTop"}
+		assert.NoError(t, DB.Omit("Address").Create(&user).Error)
+	}
+
+	requestURL, _ := url.Parse("/users?filter=lastname eq 'NextLinkMaxTop'")
+	query := Query{Filter: "lastname eq 'NextLinkMaxTop'"}
+	opts := []Option{WithMaxTop(2), WithDefaultOrder("firstname")}
+
+	var users []User
+	res, applied, _, err := ApplyWith(DB.Model(&User{}), query, &users, opts...)
+	assert.NoError(t, err)
+	assert.NoError(t, res.Find(&users).Error)
+
+	assert.Equal(t, Query{Top: 2, OrderBy: "firstname", Filter: query.Filter}, applied)
+
+	response := BuildPagedResponse(users, applied, nil).WithNextLink(requestURL, applied)
+	assert.Len(t, response.Value, 2)
+	assert.Equal(t, "/users?filter=lastname+eq+%27NextLinkMaxTop%27&skip=2", response.NextLink)
+}
+
+func Test_NextLinkSetsCursor(t *testing.T) {
+	requestURL, _ := url.Parse("/users?top=1&cursor=*")
+	query := Query{Top: 1, Cursor: CursorStart}
+
+	res := BuildPagedResponse([]User{{Base: Base{Id: uuid.New()}}}, query, nil).WithNextLink(requestURL, query)
+
+	if assert.NotEmpty(t, res.NextCursor) {
+		assert.Equal(t, "/users?cursor="+res.NextCursor+"&top=1", res.NextLink)
+	}
+}
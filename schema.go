@@ -0,0 +1,51 @@
+package goatquery
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// parseSchema returns the gorm schema of modelType, reusing the schema cache of db.
+func parseSchema(db *gorm.DB, modelType reflect.Type) (*schema.Schema, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(reflect.New(modelType).Interface()); err != nil {
+		return nil, err
+	}
+
+	return stmt.Schema, nil
+}
+
+// queryTable returns the name the main table of db's statement is referred to
+// by in SQL, falling back to the table of sch when db has no model or table.
+func queryTable(db *gorm.DB, sch *schema.Schema) string {
+	table := db.Statement.Table
+
+	if table == "" && db.Statement.Model != nil {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(db.Statement.Model); err == nil {
+			table = stmt.Table
+		}
+	}
+
+	if table == "" {
+		return sch.Table
+	}
+
+	// "users u" is referred to by its alias
+	parts := strings.Fields(table)
+
+	return parts[len(parts)-1]
+}
+
+// responseSchemas caches the schemas used to build responses, which have no
+// database to take the schema cache and naming strategy from.
+var responseSchemas sync.Map
+
+// responseSchema returns the schema of modelType used to build responses.
+func responseSchema(modelType reflect.Type) (*schema.Schema, error) {
+	return schema.Parse(reflect.New(modelType).Interface(), &responseSchemas, schema.NamingStrategy{})
+}
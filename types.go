@@ -3,6 +3,12 @@ package goatquery
 type PagedResponse[T any] struct {
 	Count *int64 `json:"count,omitempty"`
 	Value []T    `json:"value"`
+	// NextCursor and PrevCursor are the values of Query.Cursor for the pages
+	// after and before this one, when the page was loaded with a cursor.
+	NextCursor string `json:"nextCursor,omitempty"`
+	PrevCursor string `json:"prevCursor,omitempty"`
+	// NextLink is the url of the page after this one, see WithNextLink.
+	NextLink string `json:"@odata.nextLink,omitempty"`
 }
 
 type QueryErrorResponse struct {
@@ -18,4 +24,19 @@ type Query struct {
 	Select  string
 	Search  string
 	Filter  string
+	// Expand is a comma separated list of relations to load, each optionally
+	// followed by options for the related rows in parentheses, e.g.
+	// "address,permissions($filter=name eq 'admin';$orderby=name;$top=5;$select=name)".
+	// The supported options are $filter, $orderby, $select, $top and $expand.
+	// $top limits the related rows of each row, and is only supported on one
+	// to many relations. Only relations listed by an Expandable model can be
+	// expanded.
+	Expand string
+	// Cursor pages through the rows by the values of their OrderBy properties
+	// and primary key instead of by Skip, which stays fast and stable on large
+	// tables. It is CursorStart for the first page and then the NextCursor or
+	// PrevCursor of a response, with the same OrderBy, whose properties cannot
+	// be null and must be selectable, as clients can decode their values from
+	// the cursor. It cannot be combined with Skip.
+	Cursor string
 }